@@ -0,0 +1,153 @@
+package loopia
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Actions in an AuditEntry.
+const (
+	AuditAddRecord         = "add_record"
+	AuditUpdateRecord      = "update_record"
+	AuditRemoveRecord      = "remove_record"
+	AuditAddSubdomain      = "add_subdomain"
+	AuditRemoveSubdomain   = "remove_subdomain"
+	AuditAddDomain         = "add_domain"
+	AuditRemoveDomain      = "remove_domain"
+	AuditOrderDomain       = "order_domain"
+	AuditPayInvoice        = "pay_invoice"
+	AuditUpdateNameservers = "update_nameservers"
+)
+
+// Outcomes in an AuditEntry.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditRecord is the value of a record before or after a change.
+type AuditRecord struct {
+	Type     string `json:"type"`
+	Data     string `json:"data"`
+	TTL      int    `json:"ttl"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+// AuditEntry describes one change made to a zone, successful or not.
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	User     string    `json:"user"`
+	Customer string    `json:"customer,omitempty"`
+	// Zone is the Loopia domain and Name the subdomain in it.
+	Zone    string       `json:"zone"`
+	Name    string       `json:"name"`
+	ID      int64        `json:"id,omitempty"`
+	Before  *AuditRecord `json:"before,omitempty"`
+	After   *AuditRecord `json:"after,omitempty"`
+	Trace   string       `json:"trace,omitempty"`
+	Outcome string       `json:"outcome"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// AuditSink receives an entry for every change the provider makes. A failing
+// sink is logged but does not fail the change.
+type AuditSink interface {
+	WriteAudit(ctx context.Context, entry AuditEntry) error
+}
+
+type auditHolder struct {
+	sink AuditSink
+}
+
+// SetAuditSink sets where the provider reports changes, nil turns it off.
+func (p *Provider) SetAuditSink(sink AuditSink) {
+	if sink == nil {
+		p.state().auditSink.Store(nil)
+		return
+	}
+	p.state().auditSink.Store(&auditHolder{sink})
+}
+
+// audit completes entry and writes it to the audit sink, if there is one.
+func (p *Provider) audit(ctx context.Context, entry AuditEntry, err error) {
+	h := p.state().auditSink.Load()
+	if h == nil {
+		return
+	}
+	entry.Time = time.Now().UTC()
+	entry.User = p.Username
+	entry.Customer = p.Customer
+	entry.Trace = getTrace(ctx)
+	entry.Outcome = AuditSuccess
+	if err != nil {
+		entry.Outcome = AuditFailure
+		entry.Error = err.Error()
+	}
+	if err := h.sink.WriteAudit(ctx, entry); err != nil {
+		p.log().WarnContext(ctx, "unexpected error writing audit entry", "error", err, LogKeyZone, entry.Zone, LogKeyName, entry.Name)
+	}
+}
+
+func auditRecord(r loopiaRecord) *AuditRecord {
+	return &AuditRecord{
+		Type:     r.Type,
+		Data:     r.RData,
+		TTL:      r.TTL,
+		Priority: r.Priority,
+	}
+}
+
+// JSONLinesAudit is an AuditSink writing one JSON object per line. It is safe
+// for concurrent use.
+type JSONLinesAudit struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLinesAudit returns a sink writing to w.
+func NewJSONLinesAudit(w io.Writer) *JSONLinesAudit {
+	return &JSONLinesAudit{w: w}
+}
+
+// OpenJSONLinesAudit opens, or creates, the file at path for appending
+// entries. Close it when done.
+func OpenJSONLinesAudit(path string) (*JSONLinesAudit, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONLinesAudit(f), nil
+}
+
+// WriteAudit implements AuditSink.
+func (a *JSONLinesAudit) WriteAudit(_ context.Context, entry AuditEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.w.Write(b)
+	return err
+}
+
+// Close closes the underlying writer if it is an io.Closer.
+func (a *JSONLinesAudit) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if c, ok := a.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Interface guards
+var (
+	_ AuditSink = (*JSONLinesAudit)(nil)
+)
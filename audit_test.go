@@ -0,0 +1,111 @@
+package loopia
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryAudit struct {
+	entries []AuditEntry
+}
+
+func (a *memoryAudit) WriteAudit(_ context.Context, entry AuditEntry) error {
+	a.entries = append(a.entries, entry)
+	return nil
+}
+
+func TestProvider_SetAuditSink(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	old := zone.add("www", loopiaRecord{Type: "TXT", RData: "old", TTL: 300})
+	sink := &memoryAudit{}
+	p := tc.getProvider()
+	p.Username = "user@loopiaapi"
+	p.SetAuditSink(sink)
+	ctx := writeTrace(context.TODO(), "test")
+
+	_, err := p.AppendRecords(ctx, "test.local", []libdns.Record{libdns.TXT{Name: "_acme", Text: "new", TTL: time.Hour}})
+	assert.NoError(t, err)
+	added := zone.get("_acme")[0]
+	_, err = p.updateZoneRecord(ctx, "test.local", libdns.TXT{Name: "www", Text: "updated", TTL: time.Hour}, zone.get("www")[0])
+	assert.NoError(t, err)
+	_, err = p.DeleteRecords(ctx, "test.local", []libdns.Record{libdns.TXT{Name: "_acme"}})
+	assert.NoError(t, err)
+
+	assert.Len(t, sink.entries, 5)
+	for _, e := range sink.entries {
+		assert.Equal(t, "user@loopiaapi", e.User)
+		assert.Equal(t, "test.local", e.Zone)
+		assert.Equal(t, AuditSuccess, e.Outcome)
+		assert.False(t, e.Time.IsZero())
+		assert.NotEmpty(t, e.Trace)
+	}
+	assert.Equal(t, AuditAddSubdomain, sink.entries[0].Action)
+	assert.Equal(t, "_acme", sink.entries[0].Name)
+
+	assert.Equal(t, AuditAddRecord, sink.entries[1].Action)
+	assert.Equal(t, added.ID, sink.entries[1].ID)
+	assert.Equal(t, &AuditRecord{Type: "TXT", Data: "new", TTL: 3600}, sink.entries[1].After)
+
+	assert.Equal(t, AuditUpdateRecord, sink.entries[2].Action)
+	assert.Equal(t, old[0], sink.entries[2].ID)
+	assert.Equal(t, &AuditRecord{Type: "TXT", Data: "old", TTL: 300}, sink.entries[2].Before)
+	assert.Equal(t, &AuditRecord{Type: "TXT", Data: "updated", TTL: 3600}, sink.entries[2].After)
+
+	assert.Equal(t, AuditRemoveRecord, sink.entries[3].Action)
+	assert.Equal(t, added.ID, sink.entries[3].ID)
+	assert.Equal(t, &AuditRecord{Type: "TXT", Data: "new", TTL: 3600}, sink.entries[3].Before)
+	assert.Equal(t, AuditRemoveSubdomain, sink.entries[4].Action)
+
+	tc.handle("removeZoneRecord", func(t *testing.T, w http.ResponseWriter, params []string) {
+		writeValue(w, stringValue("AUTH_ERROR"))
+	})
+	_, err = p.DeleteRecords(ctx, "test.local", []libdns.Record{libdns.TXT{Name: "www"}})
+	assert.Error(t, err)
+	last := sink.entries[len(sink.entries)-1]
+	assert.Equal(t, AuditRemoveRecord, last.Action)
+	assert.Equal(t, AuditFailure, last.Outcome)
+	assert.Contains(t, last.Error, "AUTH_ERROR")
+}
+
+func TestJSONLinesAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	a, err := OpenJSONLinesAudit(path)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := a.WriteAudit(context.TODO(), AuditEntry{Action: AuditAddRecord, Zone: "example.org", ID: int64(i)})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	assert.NoError(t, a.Close())
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	seen := make(map[int64]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		e := AuditEntry{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		seen[e.ID] = true
+	}
+	assert.Len(t, seen, 50)
+}
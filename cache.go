@@ -0,0 +1,135 @@
+package loopia
+
+import (
+	"sync"
+	"time"
+)
+
+// cacheKey identifies the records of one subdomain in a Loopia domain.
+type cacheKey struct {
+	domain string
+	name   string
+}
+
+// newCacheKey returns the key of name in zone. Entries are always keyed by
+// the loopified domain and subdomain, so the records of www in the zone
+// sub.example.org are the same entry as those of www.sub in example.org.
+func newCacheKey(zone, name string) cacheKey {
+	n, d := loopify(name, cleanZone(zone))
+	return cacheKey{d, n}
+}
+
+// cacheDomain returns the Loopia domain zone is stored under.
+func cacheDomain(zone string) string {
+	return newCacheKey(zone, "").domain
+}
+
+type cachedSubdomains struct {
+	names   []string
+	expires time.Time
+}
+
+type cachedRecords struct {
+	records []loopiaRecord
+	expires time.Time
+}
+
+// zoneCache is a read-through cache of getSubdomains and getZoneRecords
+// results, keyed by Loopia domain and subdomain.
+//
+// Every invalidation bumps the generation. Reads remember the generation
+// they started in and their result is only stored if nothing was
+// invalidated in the meantime.
+type zoneCache struct {
+	mu         sync.Mutex
+	gen        uint64
+	subdomains map[string]cachedSubdomains
+	records    map[cacheKey]cachedRecords
+}
+
+func (c *zoneCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+func (c *zoneCache) getSubdomains(domain string) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.subdomains[cacheDomain(domain)]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return append([]string{}, e.names...), true
+}
+
+func (c *zoneCache) putSubdomains(gen uint64, domain string, names []string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	if c.subdomains == nil {
+		c.subdomains = make(map[string]cachedSubdomains)
+	}
+	c.subdomains[cacheDomain(domain)] = cachedSubdomains{
+		names:   append([]string{}, names...),
+		expires: time.Now().Add(ttl),
+	}
+}
+
+func (c *zoneCache) getRecords(domain, name string) ([]loopiaRecord, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.records[newCacheKey(domain, name)]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return append([]loopiaRecord{}, e.records...), true
+}
+
+func (c *zoneCache) putRecords(gen uint64, domain, name string, records []loopiaRecord, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	if c.records == nil {
+		c.records = make(map[cacheKey]cachedRecords)
+	}
+	c.records[newCacheKey(domain, name)] = cachedRecords{
+		records: append([]loopiaRecord{}, records...),
+		expires: time.Now().Add(ttl),
+	}
+}
+
+// invalidateRecords drops the cached records of a single subdomain.
+func (c *zoneCache) invalidateRecords(domain, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	delete(c.records, newCacheKey(domain, name))
+}
+
+// invalidateSubdomains drops the cached list of subdomains for a domain.
+func (c *zoneCache) invalidateSubdomains(domain string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	delete(c.subdomains, cacheDomain(domain))
+}
+
+// invalidateDomain drops everything cached for the Loopia domain of zone,
+// including the entries of other zones below that domain.
+func (c *zoneCache) invalidateDomain(zone string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	domain := cacheDomain(zone)
+	delete(c.subdomains, domain)
+	for k := range c.records {
+		if k.domain == domain {
+			delete(c.records, k)
+		}
+	}
+}
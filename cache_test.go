@@ -0,0 +1,123 @@
+package loopia
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_zoneCache(t *testing.T) {
+	c := &zoneCache{}
+	_, ok := c.getSubdomains("example.org")
+	assert.False(t, ok, "empty cache should miss")
+
+	c.putSubdomains(0, "example.org", []string{"www"}, time.Minute)
+	c.putRecords(0, "example.org", "www", []loopiaRecord{{ID: 1}}, time.Minute)
+	c.putRecords(0, "sub.example.org", "www", []loopiaRecord{{ID: 2}}, time.Minute)
+	c.putRecords(0, "example.com", "www", []loopiaRecord{{ID: 3}}, time.Minute)
+
+	names, ok := c.getSubdomains("example.org")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"www"}, names)
+	names[0] = "changed"
+	names, _ = c.getSubdomains("example.org")
+	assert.Equal(t, []string{"www"}, names, "returned slice must be a copy")
+
+	c.invalidateRecords("example.org", "www")
+	_, ok = c.getRecords("example.org", "www")
+	assert.False(t, ok)
+	_, ok = c.getSubdomains("example.org")
+	assert.True(t, ok, "invalidating records should keep subdomains")
+
+	records, ok := c.getRecords("example.org.", "www.sub")
+	assert.True(t, ok, "zones below the domain should share its entries")
+	assert.Equal(t, []loopiaRecord{{ID: 2}}, records)
+	c.invalidateRecords("example.org", "www.sub")
+	_, ok = c.getRecords("sub.example.org", "www")
+	assert.False(t, ok, "invalidating the loopified name should drop the sub-zone entry")
+	c.putRecords(c.generation(), "sub.example.org", "www", []loopiaRecord{{ID: 2}}, time.Minute)
+
+	c.invalidateDomain("example.org")
+	_, ok = c.getSubdomains("example.org")
+	assert.False(t, ok)
+	_, ok = c.getRecords("sub.example.org", "www")
+	assert.False(t, ok)
+	_, ok = c.getRecords("example.com", "www")
+	assert.True(t, ok, "other domains should be kept")
+
+	c.putRecords(c.generation(), "example.com", "www", []loopiaRecord{{ID: 3}}, -time.Second)
+	_, ok = c.getRecords("example.com", "www")
+	assert.False(t, ok, "expired entries should miss")
+
+	gen := c.generation()
+	c.invalidateSubdomains("example.com")
+	c.putRecords(gen, "example.com", "www", []loopiaRecord{{ID: 3}}, time.Minute)
+	_, ok = c.getRecords("example.com", "www")
+	assert.False(t, ok, "reads started before an invalidation should not be stored")
+}
+
+func TestProvider_CacheTTL(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	p := tc.getProvider()
+	p.CacheTTL = time.Minute
+	ctx := context.TODO()
+
+	first, err := p.GetRecords(ctx, "test.local")
+	assert.NoError(t, err)
+	subdomains, zoneRecords := tc.callCount("getSubdomains"), tc.callCount("getZoneRecords")
+
+	second, err := p.GetRecords(ctx, "test.local")
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, subdomains, tc.callCount("getSubdomains"), "getSubdomains should be cached")
+	assert.Equal(t, zoneRecords, tc.callCount("getZoneRecords"), "getZoneRecords should be cached")
+
+	p.updateZoneRecord(ctx, "test.local", libdns.Address{Name: "www", IP: netip.MustParseAddr("1.1.1.1")}, loopiaRecord{ID: 12345})
+	_, err = p.GetRecords(ctx, "test.local")
+	assert.NoError(t, err)
+	assert.Equal(t, subdomains, tc.callCount("getSubdomains"))
+	assert.Equal(t, zoneRecords+1, tc.callCount("getZoneRecords"), "only the updated name should be refetched")
+
+	p.Invalidate("test.local.")
+	_, err = p.GetRecords(ctx, "test.local")
+	assert.NoError(t, err)
+	assert.Equal(t, subdomains+1, tc.callCount("getSubdomains"))
+	assert.Equal(t, 2*zoneRecords+1, tc.callCount("getZoneRecords"))
+}
+
+func TestProvider_CacheTTL_subZone(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	zone.add("www.sub", loopiaRecord{Type: "TXT", RData: "old", TTL: 300})
+	p := tc.getProvider()
+	p.CacheTTL = time.Minute
+	ctx := context.TODO()
+
+	got, err := p.GetRecords(ctx, "sub.test.local")
+	assert.NoError(t, err)
+	assert.Len(t, got, 1)
+	got, err = p.GetRecordsByName(ctx, "sub.test.local", "www")
+	assert.NoError(t, err)
+	assert.Len(t, got, 1)
+
+	_, err = p.AppendRecords(ctx, "sub.test.local", []libdns.Record{
+		libdns.TXT{Name: "www", Text: "new", TTL: 5 * time.Minute},
+		libdns.TXT{Name: "_acme-challenge", Text: "token", TTL: 5 * time.Minute},
+	})
+	assert.NoError(t, err)
+
+	got, err = p.GetRecords(ctx, "sub.test.local")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []libdns.Record{
+		libdns.TXT{Name: "www", Text: "old", TTL: 5 * time.Minute},
+		libdns.TXT{Name: "www", Text: "new", TTL: 5 * time.Minute},
+		libdns.TXT{Name: "_acme-challenge", Text: "token", TTL: 5 * time.Minute},
+	}, got, "an append to a sub-zone should not leave its cached records stale")
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kolo/xmlrpc"
@@ -16,9 +18,42 @@ const (
 	apiurl = "https://api.loopia.se/RPCSERV"
 )
 
+const (
+	statusOK        = "OK"
+	statusError     = "ERROR"
+	statusAuthError = "AUTH_ERROR"
+)
+
+// client holds the state of a Provider behind a pointer, created on first
+// use, so a Provider can be copied and printed by value without copying locks.
+// Copies share the state.
 type client struct {
-	rpc   *xmlrpc.Client
-	mutex sync.Mutex
+	shared atomic.Value
+}
+
+// state returns the state of the provider, creating it on first use.
+func (c *client) state() *clientState {
+	if s, ok := c.shared.Load().(*clientState); ok {
+		return s
+	}
+	c.shared.CompareAndSwap(nil, &clientState{})
+	return c.shared.Load().(*clientState)
+}
+
+type clientState struct {
+	rpc        *xmlrpc.Client
+	rpcOnce    sync.Once
+	mutex      sync.RWMutex
+	cache      zoneCache
+	flights    flightGroup
+	logger     atomic.Pointer[slog.Logger]
+	tracer     atomic.Pointer[tracerHolder]
+	metrics    atomic.Pointer[metricsHolder]
+	middleware atomic.Pointer[[]Middleware]
+	auditSink  atomic.Pointer[auditHolder]
+
+	diagnostics     *callLog
+	diagnosticsOnce sync.Once
 }
 
 type libdnsKey string
@@ -84,18 +119,55 @@ func validRecord(r libdns.Record) bool {
 	return true
 }
 
+// getRPC returns the XML-RPC client, creating it once on first use.
 func (p *Provider) getRPC() *xmlrpc.Client {
-	if p.rpc == nil {
+	s := p.state()
+	s.rpcOnce.Do(func() {
+		if s.rpc != nil {
+			return
+		}
 		rpc, err := xmlrpc.NewClient(apiurl, nil)
 		if err != nil {
 			panic(err)
 		}
-		p.rpc = rpc
+		s.rpc = rpc
+	})
+	return s.rpc
+}
+
+func (p *Provider) call(ctx context.Context, serviceMethod string, args []interface{}, reply interface{}) error {
+	ctx, span := p.startRPCSpan(ctx, serviceMethod, args)
+	err := p.invoker()(ctx, serviceMethod, args, reply)
+	finishRPCSpan(span, reply, err)
+	p.log().DebugContext(ctx, "called rpc", LogKeyMethod, serviceMethod, "params", p.redactParams(args), "error", err)
+	return err
+}
+
+// send is the end of the middleware chain. It makes the call and reports it to
+// the metrics and diagnostics, calls stopped by middleware, like those Throttle
+// gives up on, never reach the API and are not reported.
+func (p *Provider) send(ctx context.Context, serviceMethod string, args []interface{}, reply interface{}) error {
+	start := time.Now()
+	err := p.invoke(ctx, serviceMethod, args, reply)
+	duration, status := time.Since(start), rpcStatus(reply, err)
+	p.getMetrics().ObserveCall(serviceMethod, status, duration)
+	call := CallRecord{
+		Time:     start.UTC(),
+		Method:   serviceMethod,
+		Params:   p.redactParams(args),
+		Duration: duration,
+		Status:   status,
+	}
+	if err != nil {
+		call.Error = err.Error()
 	}
-	return p.rpc
+	p.Diagnostics().record(call)
+	return err
 }
 
-func (p *Provider) call(serviceMethod string, args []interface{}, reply interface{}) error {
+// invoke adds the credentials and customer to args and calls the API. The
+// password is removed from any error returned.
+func (p *Provider) invoke(ctx context.Context, serviceMethod string, args []interface{}, reply interface{}) error {
 	params := []interface{}{
 		p.Username,
 		p.Password,
@@ -109,12 +181,89 @@ func (p *Provider) call(serviceMethod string, args []interface{}, reply interfac
 		params,
 		reply,
 	)
-	if p.logging {
-		Log().Debugw("called rpc", "method", serviceMethod, "params", args, "error", err)
+	return p.redactError(err)
+}
+
+// rpcStatus returns the Loopia status of a call. That is the status replied by
+// methods like addZoneRecord, OK for calls replying with data and ERROR for
+// calls that failed.
+func rpcStatus(reply interface{}, err error) string {
+	if err != nil {
+		return statusError
 	}
-	return err
+	switch r := reply.(type) {
+	case *string:
+		if *r != "" {
+			return *r
+		}
+	case *interface{}:
+		// Methods listing data reply with a single status when they fail.
+		if s, ok := (*r).(string); ok && s != "" {
+			return s
+		}
+	}
+	return statusOK
 }
 
+// getSubdomains lists the subdomains of a Loopia domain, using the cache
+// when CacheTTL is set. Concurrent calls for the same domain share one request.
+func (p *Provider) getSubdomains(ctx context.Context, zone string) ([]string, error) {
+	zone = cleanZone(zone)
+	if p.CacheTTL > 0 {
+		if names, ok := p.state().cache.getSubdomains(zone); ok {
+			p.log().DebugContext(ctx, "getSubdomains cache hit", LogKeyZone, zone)
+			return names, nil
+		}
+	}
+	gen := p.state().cache.generation()
+	key := fmt.Sprintf("%d getSubdomains %s", gen, zone)
+	v, err := p.state().flights.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		names := []string{}
+		if err := p.call(ctx, "getSubdomains", params(zone), &names); err != nil {
+			return nil, err
+		}
+		if p.CacheTTL > 0 {
+			p.state().cache.putSubdomains(gen, zone, names, p.CacheTTL)
+		}
+		return names, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{}, v.([]string)...), nil
+}
+
+// getSubdomainRecords lists the records of a single subdomain, using the
+// cache when CacheTTL is set. Concurrent calls for the same subdomain share
+// one request.
+func (p *Provider) getSubdomainRecords(ctx context.Context, zone, name string) ([]loopiaRecord, error) {
+	zone = cleanZone(zone)
+	if p.CacheTTL > 0 {
+		if records, ok := p.state().cache.getRecords(zone, name); ok {
+			p.log().DebugContext(ctx, "getZoneRecords cache hit", LogKeyZone, zone, LogKeyName, name)
+			return records, nil
+		}
+	}
+	gen := p.state().cache.generation()
+	key := fmt.Sprintf("%d getZoneRecords %s %s", gen, zone, name)
+	v, err := p.state().flights.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		records := []loopiaRecord{}
+		if err := p.call(ctx, "getZoneRecords", params(zone, name), &records); err != nil {
+			return nil, err
+		}
+		if p.CacheTTL > 0 {
+			p.state().cache.putRecords(gen, zone, name, records, p.CacheTTL)
+		}
+		return records, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]loopiaRecord{}, v.([]loopiaRecord)...), nil
+}
+
+// getLoopiaRecords appends the records of a single subdomain to records. The
+// name is used as is, loopify it first if needed.
 func (p *Provider) getLoopiaRecords(ctx context.Context, zone, name string, records *[]loopiaRecord) error {
 	if !validZone(zone) {
 		return fmt.Errorf("invalid zone '%s'", zone)
@@ -122,42 +271,60 @@ func (p *Provider) getLoopiaRecords(ctx context.Context, zone, name string, reco
 	if name == "" {
 		return fmt.Errorf("invalide name '%s'", name)
 	}
-	if p.logging {
-		Log().Debugw("getLoopiaRecords", "zone", zone, "name", name, "trace", getTrace(ctx))
-	}
-	names := []string{}
-	err := p.call("getSubdomains", params(cleanZone(zone)), &names)
+	p.log().DebugContext(ctx, "getLoopiaRecords", LogKeyZone, zone, LogKeyName, name)
+	found, err := p.getSubdomainRecords(ctx, zone, name)
 	if err != nil {
-		return fmt.Errorf("unexpected error getting subdomains: %w", err)
+		p.log().ErrorContext(ctx, "error calling getZoneRecords", "error", err, LogKeyZone, zone, LogKeyName, name)
+		return fmt.Errorf("error calling getZoneRecords: %w", err)
+	}
+	*records = append(*records, found...)
+	return nil
+}
+
+// lookupRecords fetches the records of the given names, relative to zone,
+// straight through getZoneRecords without listing all subdomains. If types
+// are given only records of those types are returned.
+func (p *Provider) lookupRecords(ctx context.Context, zone string, names []string, types []string) ([]libdns.Record, error) {
+	if !validZone(zone) {
+		return nil, fmt.Errorf("invalide zone '%s'", zone)
 	}
 	if len(names) == 0 {
-		if p.logging {
-			Log().Debugw("no subdomains found", "zone", zone, "name", name, "trace", getTrace(ctx))
-		}
-		return nil
+		return nil, fmt.Errorf("names is nil or empty")
 	}
-
-	// records := []loopiaRecord{}
-	if p.logging {
-		Log().Debugw("getLoopiaRecords", "zone", zone, "name", name, "trace", getTrace(ctx))
+	zone = cleanZone(zone)
+	ctx = addTrace(ctx, "lookupRecords")
+	wanted := make(map[string]bool)
+	for _, t := range types {
+		wanted[strings.ToUpper(t)] = true
 	}
-	err = p.call("getZoneRecords", params(zone, name), records)
-	if err != nil {
-		if p.logging {
-			Log().Errorw("error calling getZoneRecords", "err", err, "zone", zone, "name", name, "trace", getTrace(ctx))
+	result := []libdns.Record{}
+	for _, name := range names {
+		n, z := loopify(name, zone)
+		records := []loopiaRecord{}
+		if err := p.getLoopiaRecords(ctx, z, n, &records); err != nil {
+			return nil, err
+		}
+		for _, r := range records {
+			if len(wanted) > 0 && !wanted[strings.ToUpper(r.Type)] {
+				continue
+			}
+			rr, err := r.libdnsRecord(name)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected error converting record: %w", err)
+			}
+			result = append(result, rr)
 		}
-		return fmt.Errorf("error calling getZoneRecords: %w", err)
 	}
-	return nil
+	return result, nil
 }
 
+// getRecords fetches the records of name, relative to zone.
 func (p *Provider) getRecords(ctx context.Context, zone, name string) ([]libdns.Record, error) {
-	if p.logging {
-		Log().Debugw("getRecords", "zone", zone, "name", name)
-		ctx = addTrace(ctx, "getRecords")
-	}
+	ctx = addTrace(ctx, "getRecords")
+	p.log().DebugContext(ctx, "getRecords", LogKeyZone, zone, LogKeyName, name)
+	n, z := loopify(name, zone)
 	records := []loopiaRecord{}
-	if err := p.getLoopiaRecords(ctx, zone, name, &records); err != nil {
+	if err := p.getLoopiaRecords(ctx, z, n, &records); err != nil {
 		return nil, err
 	}
 
@@ -172,51 +339,106 @@ func (p *Provider) getRecords(ctx context.Context, zone, name string) ([]libdns.
 	return result, nil
 }
 
-func (p *Provider) addRecord(ctx context.Context, zone string, record libdns.Record, withSubdomain bool) (out libdns.Record, id int64, err error) {
-	if p.logging {
-		Log().Debugw("addRecord",
-			"zone", zone,
-			"record", record,
-			"withSubdomain", withSubdomain,
-		)
-		ctx = addTrace(ctx, "addRecord")
-	}
-	name := record.RR().Name
+// addRecord adds a single record to the subdomain name, and the subdomain if
+// asked to. It does not look up the ID of the new record, see resolveAddedRecords,
+// and leaves auditing a successful add to auditAdded once the ID is known.
+func (p *Provider) addRecord(ctx context.Context, zone, name string, record libdns.Record, withSubdomain bool) error {
+	ctx = addTrace(ctx, "addRecord")
+	p.log().DebugContext(ctx, "addRecord",
+		LogKeyZone, zone,
+		LogKeyName, name,
+		"record", record,
+		"withSubdomain", withSubdomain,
+	)
 	loopiaToAdd, err := toLoopiaRecord(record, 0)
 	if err != nil {
-		return nil, 0, fmt.Errorf("unexpected error converting record: %w", err)
+		return fmt.Errorf("unexpected error converting record: %w", err)
 	}
 	if withSubdomain {
-		var response string
-		err := p.call("addSubdomain", params(zone, name), &response)
-		if err != nil {
-			return nil, 0, fmt.Errorf("unexpected error adding subdomain: %w", err)
+		// the subdomain may exist without records, a status reply is left
+		// for addZoneRecord to fail on if it matters
+		err := p.addSubdomain(ctx, zone, name)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			p.log().WarnContext(ctx, "unexpected status adding subdomain", "error", err, LogKeyZone, zone, LogKeyName, name)
+		} else if err != nil {
+			return err
 		}
 	}
 
 	var result string
-	if err := p.call("addZoneRecord", params(zone, name, loopiaToAdd), &result); err != nil || result != "OK" {
-		return nil, 0, fmt.Errorf("unexpected error adding zone record: %w", err)
+	err = p.call(ctx, "addZoneRecord", params(zone, name, loopiaToAdd), &result)
+	p.state().cache.invalidateRecords(cleanZone(zone), name)
+	if err == nil && result != "OK" {
+		err = fmt.Errorf("unexpected error adding zone record: %w", &StatusError{Method: "addZoneRecord", Status: result})
+	} else if err != nil {
+		err = fmt.Errorf("unexpected error adding zone record: %w", err)
 	}
-	if p.logging {
-		Log().Debugw("getting records to fetch ID", "zone", zone, "name", name)
+	if err != nil {
+		p.audit(ctx, AuditEntry{Action: AuditAddRecord, Zone: zone, Name: name, After: auditRecord(loopiaToAdd)}, err)
+	}
+	return err
+}
+
+// auditAdded audits records successfully added to the subdomain name. The ids
+// are nil if they could not be resolved.
+func (p *Provider) auditAdded(ctx context.Context, zone, name string, added []libdns.Record, ids []int64) {
+	for i, r := range added {
+		var id int64
+		if ids != nil {
+			id = ids[i]
+		}
+		p.audit(ctx, AuditEntry{
+			Action: AuditAddRecord,
+			Zone:   zone,
+			Name:   name,
+			ID:     id,
+			After:  auditRecord(mustToLoopiaRecord(r, id)),
+		}, nil)
 	}
+}
+
+// resolveAddedRecords reads the subdomain name once and pairs each of the added
+// records with a record that was not among the known IDs before the add. Every
+// record read is used at most once so duplicates resolve to different IDs.
+// The records returned keep the names of the added records.
+func (p *Provider) resolveAddedRecords(ctx context.Context, zone, name string, added []libdns.Record, known map[int64]bool) ([]libdns.Record, []int64, error) {
+	p.log().DebugContext(ctx, "getting records to fetch ID", LogKeyZone, zone, LogKeyName, name, "count", len(added))
 	records := []loopiaRecord{}
 	if err := p.getLoopiaRecords(ctx, zone, name, &records); err != nil {
-		return nil, 0, fmt.Errorf("unexpected error getting zone records after add: %w", err)
+		return nil, nil, fmt.Errorf("unexpected error getting zone records after add: %w", err)
 	}
 
+	fresh := []loopiaRecord{}
 	for _, r := range records {
-		out, err = r.libdnsRecord(name)
-		if err != nil {
-			return nil, 0, fmt.Errorf("unexpected error converting record: %w", err)
-		}
-		if libdnsRecordEqual(record, out) {
-			return out, r.ID, nil
+		if !known[r.ID] {
+			fresh = append(fresh, r)
 		}
+	}
 
+	out := make([]libdns.Record, len(added))
+	ids := make([]int64, len(added))
+	used := make(map[int64]bool)
+	for i, record := range added {
+		for _, r := range fresh {
+			if used[r.ID] {
+				continue
+			}
+			rr, err := r.libdnsRecord(record.RR().Name)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unexpected error converting record: %w", err)
+			}
+			if libdnsRecordEqual(record, rr) {
+				used[r.ID] = true
+				out[i], ids[i] = rr, r.ID
+				break
+			}
+		}
+		if out[i] == nil {
+			return nil, nil, fmt.Errorf("unable to retreive new record to get it's ID")
+		}
 	}
-	return nil, 0, fmt.Errorf("unable to retreive new record to get it's ID")
+	return out, ids, nil
 }
 
 func params(args ...interface{}) []interface{} {
@@ -224,17 +446,15 @@ func params(args ...interface{}) []interface{} {
 }
 
 func (p *Provider) getZoneRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
-	if p.logging {
-		Log().Debugw("getZoneRecords", "zone", zone)
-	}
+	p.log().DebugContext(ctx, "getZoneRecords", LogKeyZone, zone)
 	if !validZone(zone) {
 		return nil, fmt.Errorf("invalide zone '%s'", zone)
 	}
 	zone = cleanZone(zone)
-	names := []string{}
-	err := p.call("getSubdomains", params(zone), &names)
+	// names are relative to zone, also when it is below the Loopia domain
+	names, err := p.listSubdomains(ctx, zone)
 	if err != nil {
-		return nil, fmt.Errorf("unexpected error getting subdomains: %w", err)
+		return nil, err
 	}
 	result := []libdns.Record{}
 myloop:
@@ -254,13 +474,11 @@ myloop:
 }
 
 func (p *Provider) addDNSEntries(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
-	if p.logging {
-		Log().Debugw("addDNSEntries",
-			"zone", zone,
-			"records", len(records),
-		)
-		ctx = addTrace(ctx, "addDNSEntries")
-	}
+	ctx = addTrace(ctx, "addDNSEntries")
+	p.log().DebugContext(ctx, "addDNSEntries",
+		LogKeyZone, zone,
+		"records", len(records),
+	)
 	if !validZone(zone) {
 		return nil, fmt.Errorf("invalide zone '%s'", zone)
 	}
@@ -273,100 +491,347 @@ func (p *Provider) addDNSEntries(ctx context.Context, zone string, records []lib
 		}
 	}
 	zone = cleanZone(zone)
-	result := []libdns.Record{}
-	cache := make(map[string][]loopiaRecord)
-	subsCreated := make(map[string]bool)
+
+	// group the input by loopified name, keeping the order names first appear in
+	keys := []cacheKey{}
+	byName := make(map[cacheKey][]int)
+	for i, r := range records {
+		n, z := loopify(r.RR().Name, zone)
+		key := cacheKey{z, n}
+		if _, ok := byName[key]; !ok {
+			keys = append(keys, key)
+		}
+		byName[key] = append(byName[key], i)
+	}
+
+	done := make([]libdns.Record, len(records))
+	collect := func() []libdns.Record {
+		result := []libdns.Record{}
+		for _, r := range done {
+			if r != nil {
+				result = append(result, r)
+			}
+		}
+		return result
+	}
 OUTER:
-	for _, new := range records {
-		rrNew := new.RR()
+	for _, key := range keys {
 		select {
 		case <-ctx.Done():
 			break OUTER
 		default:
-			if cache[rrNew.Name] == nil {
-				existingRecords := []loopiaRecord{}
-				err := p.getLoopiaRecords(ctx, zone, rrNew.Name, &existingRecords)
-				if err != nil {
-					return result, err
-				}
-				cache[rrNew.Name] = existingRecords
-				if p.logging {
-					Log().Debugw("cached record", "zone", zone, "name", rrNew.Name, "count", len(existingRecords))
-				}
-			}
-			withSubdomain := false
-			if len(cache[rrNew.Name]) == 0 && !subsCreated[rrNew.Name] {
-				withSubdomain = true
-			}
-			for _, existing := range cache[rrNew.Name] {
-				if libdnsEqualLoopia(new, existing) {
-					if p.logging {
-						Log().Debugw("identical record exists, skipping",
-							"record", new,
-							"id", existing.ID)
-					}
-					result = append(result, existing.mustLibdnsRecord(rrNew.Name))
-					continue OUTER
+		}
+		existingRecords := []loopiaRecord{}
+		if err := p.getLoopiaRecords(ctx, key.domain, key.name, &existingRecords); err != nil {
+			return collect(), err
+		}
+		toAdd := []int{}
+	INPUT:
+		for _, i := range byName[key] {
+			for _, existing := range existingRecords {
+				if libdnsEqualLoopia(records[i], existing) {
+					p.log().DebugContext(ctx, "identical record exists, skipping",
+						"record", records[i],
+						LogKeyRecordID, existing.ID)
+					done[i] = existing.mustLibdnsRecord(records[i].RR().Name)
+					continue INPUT
 				}
 			}
-			if withSubdomain {
-				subsCreated[rrNew.Name] = true
-			}
+			toAdd = append(toAdd, i)
+		}
+		if len(toAdd) == 0 {
+			continue
+		}
 
-			cn, _, err := p.addRecord(ctx, zone, new, withSubdomain)
-			if err != nil {
-				return result, err
-			}
-			result = append(result, cn)
+		toAddRecords := []libdns.Record{}
+		for _, i := range toAdd {
+			toAddRecords = append(toAddRecords, records[i])
+		}
+		resolved, err := p.addToSubdomain(ctx, key, toAddRecords, existingRecords)
+		for j := range resolved {
+			done[toAdd[j]] = resolved[j]
+		}
+		if err != nil {
+			return collect(), err
 		}
 	}
-	return result, nil
+	return collect(), nil
+}
+
+// addToSubdomain adds records to the subdomain key, which has the existing
+// records, and resolves their IDs. It returns the records added, up to the
+// first failure. If the IDs can not be read the records added are returned as
+// given, with an error naming them.
+func (p *Provider) addToSubdomain(ctx context.Context, key cacheKey, records []libdns.Record, existing []loopiaRecord) ([]libdns.Record, error) {
+	known := make(map[int64]bool)
+	for _, r := range existing {
+		known[r.ID] = true
+	}
+	added := []libdns.Record{}
+	var addErr error
+	for j, r := range records {
+		withSubdomain := j == 0 && len(existing) == 0
+		if addErr = p.addRecord(ctx, key.domain, key.name, r, withSubdomain); addErr != nil {
+			break
+		}
+		added = append(added, r)
+	}
+	if len(added) == 0 {
+		return nil, addErr
+	}
+	resolved, ids, err := p.resolveAddedRecords(ctx, key.domain, key.name, added, known)
+	p.auditAdded(ctx, key.domain, key.name, added, ids)
+	if err != nil {
+		// the records exist at Loopia, return them without IDs so callers do
+		// not add them again
+		names := []string{}
+		for _, r := range added {
+			rr := r.RR()
+			names = append(names, fmt.Sprintf("%s %s %s", rr.Name, rr.Type, rr.Data))
+		}
+		err = fmt.Errorf("records added without reading their IDs (%s): %w", strings.Join(names, ", "), err)
+		return added, errors.Join(err, addErr)
+	}
+	return resolved, addErr
 }
 
 // setRecords ensures that for any (name, type) pair in the input is the only
 // records in the output zone with that (name, type) pair are those that were
-// provided in the input.
+// provided in the input. Existing records are kept or updated in place where
+// possible, new ones are added before the records left over are removed.
 func (p *Provider) setRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
 	ctx = addTrace(ctx, "setRecords")
-	for _, r := range records {
+	p.log().DebugContext(ctx, "setRecords", LogKeyZone, zone, "records", len(records))
+	if !validZone(zone) {
+		return nil, fmt.Errorf("invalide zone '%s'", zone)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("records is nil or empty")
+	}
+	for i, r := range records {
+		if !validRecord(r) {
+			return nil, fmt.Errorf("record %d is invalid", i)
+		}
+	}
+	zone = cleanZone(zone)
+
+	keys := []cacheKey{}
+	byName := make(map[cacheKey][]int)
+	for i, r := range records {
 		n, z := loopify(r.RR().Name, zone)
-		existing := []loopiaRecord{}
-		err := p.getLoopiaRecords(ctx, z, n, &existing)
-		if err != nil {
-			return nil, fmt.Errorf("unexpected error getting zone records: %w", err)
+		key := cacheKey{z, n}
+		if _, ok := byName[key]; !ok {
+			keys = append(keys, key)
 		}
+		byName[key] = append(byName[key], i)
 	}
-	return nil, errors.New("not implemented")
+
+	done := make([]libdns.Record, len(records))
+	collect := func() []libdns.Record {
+		result := []libdns.Record{}
+		for _, r := range done {
+			if r != nil {
+				result = append(result, r)
+			}
+		}
+		return result
+	}
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return collect(), err
+		}
+		existingRecords := []loopiaRecord{}
+		if err := p.getLoopiaRecords(ctx, key.domain, key.name, &existingRecords); err != nil {
+			return collect(), fmt.Errorf("unexpected error getting zone records: %w", err)
+		}
+		types := make(map[string]bool)
+		for _, i := range byName[key] {
+			types[strings.ToUpper(records[i].RR().Type)] = true
+		}
+		used := make(map[int64]bool)
+
+		// keep identical records, only updating the TTL if needed
+		pending := []int{}
+	INPUT:
+		for _, i := range byName[key] {
+			for _, existing := range existingRecords {
+				if used[existing.ID] || !libdnsEqualLoopia(records[i], existing) {
+					continue
+				}
+				used[existing.ID] = true
+				if time.Duration(existing.TTL)*time.Second == records[i].RR().TTL {
+					done[i] = existing.mustLibdnsRecord(records[i].RR().Name)
+				} else if updated, err := p.updateZoneRecord(ctx, zone, records[i], existing); err != nil {
+					return collect(), err
+				} else {
+					done[i] = updated.mustLibdnsRecord(records[i].RR().Name)
+				}
+				continue INPUT
+			}
+			pending = append(pending, i)
+		}
+
+		// reuse the records of the same type left over, then add
+		toAdd := []int{}
+	PENDING:
+		for _, i := range pending {
+			for _, existing := range existingRecords {
+				if used[existing.ID] || !strings.EqualFold(existing.Type, records[i].RR().Type) {
+					continue
+				}
+				used[existing.ID] = true
+				updated, err := p.updateZoneRecord(ctx, zone, records[i], existing)
+				if err != nil {
+					return collect(), err
+				}
+				done[i] = updated.mustLibdnsRecord(records[i].RR().Name)
+				continue PENDING
+			}
+			toAdd = append(toAdd, i)
+		}
+		if len(toAdd) > 0 {
+			toAddRecords := []libdns.Record{}
+			for _, i := range toAdd {
+				toAddRecords = append(toAddRecords, records[i])
+			}
+			resolved, err := p.addToSubdomain(ctx, key, toAddRecords, existingRecords)
+			for j := range resolved {
+				done[toAdd[j]] = resolved[j]
+			}
+			if err != nil {
+				return collect(), err
+			}
+		}
+
+		for _, existing := range existingRecords {
+			if used[existing.ID] || !types[strings.ToUpper(existing.Type)] {
+				continue
+			}
+			if err := p.removeDNSEntry(ctx, key.domain, key.name, existing); err != nil {
+				return collect(), err
+			}
+		}
+	}
+	return collect(), nil
 }
 
-func (p *Provider) updateZoneRecord(ctx context.Context, zone string, record libdns.Record, id int64) (*loopiaRecord, error) {
+// updateZoneRecord replaces the existing record before, keeping its ID, with record.
+func (p *Provider) updateZoneRecord(ctx context.Context, zone string, record libdns.Record, before loopiaRecord) (*loopiaRecord, error) {
 	if !validZone(zone) {
 		return nil, fmt.Errorf("invalide zone '%s'", zone)
 	}
-	if id == 0 {
+	if before.ID == 0 {
 		return nil, fmt.Errorf("invalid ID")
 	}
 
 	zone = cleanZone(zone)
-	updated := mustToLoopiaRecord(record, id)
+	updated, err := toLoopiaRecord(record, before.ID)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error converting record: %w", err)
+	}
 
 	var response string
 	n, z := loopify(record.RR().Name, zone)
-	err := p.call("updateZoneRecord", params(z, n, updated), &response)
+	err = p.call(ctx, "updateZoneRecord", params(z, n, updated), &response)
+	p.state().cache.invalidateRecords(cleanZone(z), n)
+	if err == nil && response != "OK" {
+		err = fmt.Errorf("unexpected error updating zone record: %w", &StatusError{Method: "updateZoneRecord", Status: response})
+	} else if err != nil {
+		err = fmt.Errorf("unexpected error updating zone record: %w", err)
+	}
+	p.audit(ctx, AuditEntry{
+		Action: AuditUpdateRecord,
+		Zone:   z,
+		Name:   n,
+		ID:     before.ID,
+		Before: auditRecord(before),
+		After:  auditRecord(updated),
+	}, err)
 	if err != nil {
-		return nil, fmt.Errorf("unexpected error updating zone record: %w", err)
-	}
-	if response != "OK" {
-		return nil, fmt.Errorf("unexpected error updating zone record: %s", response)
+		return nil, err
 	}
 
 	return &updated, nil
 }
 
 func (p *Provider) deleteRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
-	if p.logging {
-		Log().Debugw("deleteRecords", "zone", zone, "records", len(records), "trace", getTrace(ctx))
+	outcomes, err := p.deleteRecordsWithOutcome(ctx, zone, records)
+	if err != nil {
+		return nil, err
+	}
+	result := []libdns.Record{}
+	errs := []error{}
+	for _, o := range outcomes {
+		if o.Err != nil {
+			errs = append(errs, fmt.Errorf("unexpected error removing zone record %d: %w", o.ID, o.Err))
+			continue
+		}
+		result = append(result, o.Record)
+	}
+	return result, errors.Join(errs...)
+}
+
+// deletePlan holds the records to remove from one subdomain.
+type deletePlan struct {
+	zone    string
+	name    string
+	records []loopiaRecord
+	inputs  []libdns.RR // matched input per record, for the name used in the outcome
+}
+
+// planDeletes reads each subdomain once and matches the records to delete.
+// Empty type, data or TTL in a record matches any value.
+func (p *Provider) planDeletes(ctx context.Context, zone string, records []libdns.Record) ([]*deletePlan, error) {
+	plans := []*deletePlan{}
+	byName := make(map[cacheKey]*deletePlan)
+	existing := make(map[cacheKey][]loopiaRecord)
+	planned := make(map[int64]bool)
+	for i, r := range records {
+		n, z := loopify(r.RR().Name, zone)
+		key := cacheKey{z, n}
+		plan := byName[key]
+		if plan == nil {
+			ctx2 := addTrace(ctx, fmt.Sprintf("toDelete[%d]", i))
+			found, err := p.getMatchingRecordsByName(ctx2, z, n)
+			if err != nil {
+				p.log().WarnContext(ctx2, "unexpected error getting remaining records", "error", err, LogKeyZone, z, LogKeyName, n)
+				return nil, fmt.Errorf("unexpected error deleting records: %w", err)
+			}
+			plan = &deletePlan{zone: z, name: n}
+			plans = append(plans, plan)
+			byName[key] = plan
+			existing[key] = found
+		}
+
+		rr := r.RR()
+		for _, er := range existing[key] {
+			if planned[er.ID] {
+				continue
+			}
+			erl := er.mustLibdnsRecord(rr.Name).RR()
+
+			if rr.Type != "" && rr.Type != erl.Type {
+				continue
+			}
+			if rr.Data != "" && rr.Data != erl.Data {
+				continue
+			}
+			if rr.TTL != 0 && rr.TTL != erl.TTL {
+				continue
+			}
+			planned[er.ID] = true
+			plan.records = append(plan.records, er)
+			plan.inputs = append(plan.inputs, rr)
+		}
 	}
+	return plans, nil
+}
+
+// deleteRecordsWithOutcome removes every record matching the input and
+// reports the outcome for each of them. Once the records of a name are
+// removed the subdomain is checked once and removed if it is empty.
+func (p *Provider) deleteRecordsWithOutcome(ctx context.Context, zone string, records []libdns.Record) ([]DeleteOutcome, error) {
+	p.log().DebugContext(ctx, "deleteRecords", LogKeyZone, zone, "records", len(records))
 	if !validZone(zone) {
 		return nil, fmt.Errorf("invalide zone '%s'", zone)
 	}
@@ -375,55 +840,34 @@ func (p *Provider) deleteRecords(ctx context.Context, zone string, records []lib
 	}
 	zone = cleanZone(zone)
 	ctx = addTrace(ctx, "deleteRecords")
-	type args struct {
-		zone   string
-		name   string
-		record loopiaRecord
+
+	plans, err := p.planDeletes(ctx, zone, records)
+	if err != nil {
+		return nil, err
 	}
-	toDelete := []args{}
-	for i, r := range records {
-		n, z := loopify(r.RR().Name, zone)
-		ctx2 := addTrace(ctx, fmt.Sprintf("toDelete[%d]", i))
-		existing, err := p.getMatchingRecordsByName(ctx2, z, n)
-		if err != nil {
-			if p.logging {
-				Log().Warnw("unexpected error getting remaining records", "err", err, "zone", z, "name", n, "trace", getTrace(ctx2))
+	outcomes := []DeleteOutcome{}
+	for _, plan := range plans {
+		removed := 0
+		for i, r := range plan.records {
+			// once cancelled the remaining records are not tried
+			err := ctx.Err()
+			if err == nil {
+				err = p.removeDNSEntry(ctx, plan.zone, plan.name, r)
 			}
-			return nil, fmt.Errorf("unexpected error deleting records: %w", err)
-		}
-		rr := r.RR()
-		if len(existing) > 0 {
-			for _, er := range existing {
-				erl := er.mustLibdnsRecord(rr.Name).RR()
-
-				if rr.Type != "" && rr.Type != erl.Type {
-					continue
-				}
-				if rr.Data != "" && rr.Data != erl.Data {
-					continue
-				}
-				if rr.TTL != 0 && rr.TTL != erl.TTL {
-					continue
-				}
-
-				toDelete = append(toDelete, args{
-					zone:   z,
-					name:   n,
-					record: er,
-				})
+			if err == nil {
+				removed++
 			}
+			outcomes = append(outcomes, DeleteOutcome{
+				Record: r.mustLibdnsRecord(plan.inputs[i].Name),
+				ID:     r.ID,
+				Err:    err,
+			})
 		}
-	}
-	result := []libdns.Record{}
-	for _, arg := range toDelete {
-		err := p.removeDNSEntry(ctx, arg.zone, arg.name, arg.record.ID)
-		if err != nil {
-			return nil, fmt.Errorf("unexpected error removing zone record: %w", err)
+		if removed > 0 && ctx.Err() == nil {
+			p.removeEmptySubdomain(ctx, plan.zone, plan.name)
 		}
-		result = append(result, arg.record.mustLibdnsRecord(arg.name))
 	}
-
-	return result, nil
+	return outcomes, nil
 }
 
 // getMatchingRecordsByName will NOT loopify the name
@@ -443,45 +887,48 @@ func (p *Provider) getMatchingRecordsByName(ctx context.Context, zone, name stri
 	return records, nil
 }
 
-func (p *Provider) removeDNSEntry(ctx context.Context, zone, name string, id int64) error {
-	if p.logging {
-		Log().Debugw("removeDNSEntry", "zone", zone, "name", name, "id", id)
-	}
+// removeDNSEntry removes a single existing record, it leaves the subdomain in place.
+func (p *Provider) removeDNSEntry(ctx context.Context, zone, name string, record loopiaRecord) error {
+	p.log().DebugContext(ctx, "removeDNSEntry", LogKeyZone, zone, LogKeyName, name, LogKeyRecordID, record.ID)
 	if !validZone(zone) {
 		return fmt.Errorf("invalide zone '%s'", zone)
 	}
-	if id == 0 {
+	if record.ID == 0 {
 		return fmt.Errorf("invalid ID")
 	}
-	ctx = addTrace(ctx, "removeDNSEntry")
 	zone = cleanZone(zone)
 	var response string
-	err := p.call("removeZoneRecord", params(zone, name, id), &response)
-	if err != nil {
-		return fmt.Errorf("unexpected error removing zone record: %w", err)
-	}
-	if response != "OK" {
-		return fmt.Errorf("unexpected error removing zone record: %s", response)
-	}
+	err := p.call(ctx, "removeZoneRecord", params(zone, name, record.ID), &response)
+	p.state().cache.invalidateRecords(zone, name)
+	if err == nil && response != "OK" {
+		err = fmt.Errorf("unexpected error removing zone record: %w", &StatusError{Method: "removeZoneRecord", Status: response})
+	} else if err != nil {
+		err = fmt.Errorf("unexpected error removing zone record: %w", err)
+	}
+	p.audit(ctx, AuditEntry{
+		Action: AuditRemoveRecord,
+		Zone:   zone,
+		Name:   name,
+		ID:     record.ID,
+		Before: auditRecord(record),
+	}, err)
+	return err
+}
+
+// removeEmptySubdomain removes the subdomain if there are no records left in it.
+// Failures are only logged, the records have been removed either way.
+func (p *Provider) removeEmptySubdomain(ctx context.Context, zone, name string) {
+	ctx = addTrace(ctx, "removeEmptySubdomain")
+	zone = cleanZone(zone)
 	records, err := p.getMatchingRecordsByName(ctx, zone, name)
 	if err != nil {
-		if p.logging {
-			Log().Warnw("unexpected error removing zone record", "err", err, "zone", zone, "name", name, "trace", getTrace(ctx))
-		}
-		return fmt.Errorf("unexpected error removing zone record: %w", err)
+		p.log().WarnContext(ctx, "unexpected error getting remaining records", "error", err, LogKeyZone, zone, LogKeyName, name)
+		return
 	}
-	if len(records) == 0 {
-		// remove the subdomain if no records left
-		var response string
-		if p.logging {
-			Log().Debugw("removing subdomain", "zone", zone, "name", name, "trace", getTrace(ctx))
-		}
-		err := p.call("removeSubdomain", params(zone, name), &response)
-		if err != nil {
-			if p.logging {
-				Log().Warnw("unexpected error deleting subdomain", "err", err, "response", response, "trace", getTrace(ctx))
-			}
-		}
+	if len(records) > 0 {
+		return
+	}
+	if err := p.removeSubdomain(ctx, zone, name); err != nil {
+		p.log().WarnContext(ctx, "unexpected error deleting subdomain", "error", err, LogKeyZone, zone, LogKeyName, name)
 	}
-	return nil
 }
@@ -0,0 +1,307 @@
+package loopia
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider_addDNSEntries(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	existing := zone.add("_acme-challenge", loopiaRecord{Type: "TXT", RData: "foo", TTL: 300})
+	p := tc.getProvider()
+
+	got, err := p.AppendRecords(context.TODO(), "test.local", []libdns.Record{
+		libdns.TXT{Name: "_acme-challenge", Text: "foo", TTL: 5 * time.Minute},
+		libdns.TXT{Name: "_acme-challenge", Text: "bar", TTL: 5 * time.Minute},
+		libdns.TXT{Name: "www", Text: "baz", TTL: 5 * time.Minute},
+		libdns.TXT{Name: "_acme-challenge", Text: "bar", TTL: 5 * time.Minute},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []libdns.Record{
+		libdns.TXT{Name: "_acme-challenge", Text: "foo", TTL: 5 * time.Minute},
+		libdns.TXT{Name: "_acme-challenge", Text: "bar", TTL: 5 * time.Minute},
+		libdns.TXT{Name: "www", Text: "baz", TTL: 5 * time.Minute},
+		libdns.TXT{Name: "_acme-challenge", Text: "bar", TTL: 5 * time.Minute},
+	}, got)
+
+	records := zone.get("_acme-challenge")
+	assert.Len(t, records, 3)
+	assert.Equal(t, existing[0], records[0].ID, "existing record should be kept")
+	assert.Equal(t, 3, tc.callCount("addZoneRecord"))
+	assert.Equal(t, 1, tc.callCount("addSubdomain"), "only www needed a new subdomain")
+	assert.Equal(t, 4, tc.callCount("getZoneRecords"), "one read before and after adding per name")
+	assert.True(t, zone.hasSubdomain("www"))
+}
+
+func TestProvider_addDNSEntriesUnresolved(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	zone.add("www", loopiaRecord{Type: "A", RData: "192.0.2.1", TTL: 300})
+	reads := 0
+	get := tc.handler("getZoneRecords")
+	tc.handle("getZoneRecords", func(t *testing.T, w http.ResponseWriter, params []string) {
+		if reads++; reads > 1 {
+			writeValue(w, stringValue("UNKNOWN_ERROR"))
+			return
+		}
+		get(t, w, params)
+	})
+	p := tc.getProvider()
+
+	txt := libdns.TXT{Name: "www", Text: "added", TTL: 5 * time.Minute}
+	got, err := p.AppendRecords(context.TODO(), "test.local", []libdns.Record{txt})
+	assert.ErrorContains(t, err, "records added without reading their IDs (www TXT added)")
+	assert.Equal(t, []libdns.Record{txt}, got, "records created at Loopia are returned")
+	assert.Len(t, zone.get("www"), 2)
+}
+
+func TestProvider_resolveAddedRecords(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	old := zone.add("_acme-challenge", loopiaRecord{Type: "TXT", RData: "foo", TTL: 300})
+	known := map[int64]bool{old[0]: true}
+	added := zone.add("_acme-challenge",
+		loopiaRecord{Type: "TXT", RData: "foo", TTL: 300},
+		loopiaRecord{Type: "TXT", RData: "foo", TTL: 300},
+	)
+	p := tc.getProvider()
+
+	foo := libdns.TXT{Name: "_acme-challenge", Text: "foo", TTL: 5 * time.Minute}
+	out, ids, err := p.resolveAddedRecords(context.TODO(), "test.local", "_acme-challenge", []libdns.Record{foo, foo}, known)
+	assert.NoError(t, err)
+	assert.Equal(t, []libdns.Record{foo, foo}, out)
+	assert.Equal(t, added, ids, "identical records should resolve to the new IDs")
+
+	_, _, err = p.resolveAddedRecords(context.TODO(), "test.local", "_acme-challenge", []libdns.Record{foo, foo, foo}, known)
+	assert.Error(t, err, "there are only two new records")
+}
+
+func TestProvider_DeleteRecordsWithOutcome(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	ids := zone.add("_acme-challenge",
+		loopiaRecord{Type: "TXT", RData: "foo", TTL: 300},
+		loopiaRecord{Type: "TXT", RData: "bar", TTL: 300},
+		loopiaRecord{Type: "TXT", RData: "baz", TTL: 300},
+	)
+	www := zone.add("www", loopiaRecord{Type: "TXT", RData: "foo", TTL: 300})
+	p := tc.getProvider()
+
+	outcomes, err := p.DeleteRecordsWithOutcome(context.TODO(), "test.local", []libdns.Record{
+		libdns.TXT{Name: "_acme-challenge", Text: "foo"},
+		libdns.TXT{Name: "www", Text: "foo"},
+		libdns.TXT{Name: "_acme-challenge", Text: "bar"},
+		libdns.TXT{Name: "_acme-challenge", Text: "nope"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []DeleteOutcome{
+		{Record: libdns.TXT{Name: "_acme-challenge", Text: "foo", TTL: 5 * time.Minute}, ID: ids[0]},
+		{Record: libdns.TXT{Name: "_acme-challenge", Text: "bar", TTL: 5 * time.Minute}, ID: ids[1]},
+		{Record: libdns.TXT{Name: "www", Text: "foo", TTL: 5 * time.Minute}, ID: www[0]},
+	}, outcomes)
+	assert.Equal(t, 4, tc.callCount("getZoneRecords"), "one plan read and one emptiness check per name")
+	assert.Equal(t, 1, tc.callCount("removeSubdomain"))
+	assert.True(t, zone.hasSubdomain("_acme-challenge"))
+	assert.False(t, zone.hasSubdomain("www"))
+
+	tc.handle("removeZoneRecord", func(t *testing.T, w http.ResponseWriter, params []string) {
+		writeValue(w, stringValue("UNKNOWN_ERROR"))
+	})
+	outcomes, err = p.DeleteRecordsWithOutcome(context.TODO(), "test.local", []libdns.Record{
+		libdns.TXT{Name: "_acme-challenge"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, outcomes, 1)
+	assert.Error(t, outcomes[0].Err)
+
+	deleted, err := p.DeleteRecords(context.TODO(), "test.local", []libdns.Record{
+		libdns.TXT{Name: "_acme-challenge"},
+	})
+	assert.Error(t, err)
+	assert.Empty(t, deleted)
+	assert.Equal(t, 1, tc.callCount("removeSubdomain"), "nothing removed, nothing to check")
+}
+
+func TestProvider_DeleteRecordsPartial(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	ids := zone.add("_acme-challenge",
+		loopiaRecord{Type: "TXT", RData: "foo", TTL: 300},
+		loopiaRecord{Type: "TXT", RData: "bar", TTL: 300},
+		loopiaRecord{Type: "TXT", RData: "baz", TTL: 300},
+	)
+	remove := tc.handler("removeZoneRecord")
+	tc.handle("removeZoneRecord", func(t *testing.T, w http.ResponseWriter, params []string) {
+		if params[len(params)-1] == fmt.Sprint(ids[0]) {
+			writeValue(w, stringValue("UNKNOWN_ERROR"))
+			return
+		}
+		remove(t, w, params)
+	})
+	p := tc.getProvider()
+
+	deleted, err := p.DeleteRecords(context.TODO(), "test.local", []libdns.Record{
+		libdns.TXT{Name: "_acme-challenge"},
+	})
+	assert.ErrorContains(t, err, fmt.Sprintf("unexpected error removing zone record %d", ids[0]))
+	assert.Equal(t, []libdns.Record{
+		libdns.TXT{Name: "_acme-challenge", Text: "bar", TTL: 5 * time.Minute},
+		libdns.TXT{Name: "_acme-challenge", Text: "baz", TTL: 5 * time.Minute},
+	}, deleted, "records deleted after a failure are reported")
+	assert.Len(t, zone.get("_acme-challenge"), 1)
+
+	// cancelled while removing the first of two records
+	more := zone.add("_acme-challenge", loopiaRecord{Type: "TXT", RData: "qux", TTL: 300})
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	tc.handle("removeZoneRecord", func(t *testing.T, w http.ResponseWriter, params []string) {
+		cancel()
+		remove(t, w, params)
+	})
+	outcomes, err := p.DeleteRecordsWithOutcome(ctx, "test.local", []libdns.Record{
+		libdns.TXT{Name: "_acme-challenge"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, outcomes, 2)
+	assert.NoError(t, outcomes[0].Err)
+	assert.Equal(t, more[0], outcomes[1].ID)
+	assert.ErrorIs(t, outcomes[1].Err, context.Canceled)
+	assert.Equal(t, 4, tc.callCount("removeZoneRecord"), "nothing is removed once cancelled")
+}
+
+func TestProvider_GetRecordsByName(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	zone.add("_acme-challenge.sub",
+		loopiaRecord{Type: "TXT", RData: "foo", TTL: 300},
+		loopiaRecord{Type: "CNAME", RData: "other.test.local.", TTL: 300},
+	)
+	zone.add("sub", loopiaRecord{Type: "TXT", RData: "apex", TTL: 300})
+	zone.add("www", loopiaRecord{Type: "TXT", RData: "other", TTL: 300})
+	p := tc.getProvider()
+
+	got, err := p.GetRecordsByName(context.TODO(), "sub.test.local.", "_acme-challenge", "@")
+	assert.NoError(t, err)
+	assert.Equal(t, []libdns.Record{
+		libdns.TXT{Name: "_acme-challenge", Text: "foo", TTL: 5 * time.Minute},
+		libdns.CNAME{Name: "_acme-challenge", Target: "other.test.local.", TTL: 5 * time.Minute},
+		libdns.TXT{Name: "@", Text: "apex", TTL: 5 * time.Minute},
+	}, got)
+
+	got, err = p.GetRecordsByNameAndType(context.TODO(), "sub.test.local", "_acme-challenge", "txt")
+	assert.NoError(t, err)
+	assert.Equal(t, []libdns.Record{
+		libdns.TXT{Name: "_acme-challenge", Text: "foo", TTL: 5 * time.Minute},
+	}, got)
+	assert.Zero(t, tc.callCount("getSubdomains"), "lookups should not list subdomains")
+
+	_, err = p.GetRecordsByName(context.TODO(), "sub.test.local")
+	assert.Error(t, err)
+}
+
+func TestProvider_GetRecords_subZone(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	zone.add("www", loopiaRecord{Type: "TXT", RData: "other", TTL: 300})
+	p := tc.getProvider()
+
+	_, err := p.AppendRecords(context.TODO(), "sub.test.local.", []libdns.Record{
+		libdns.TXT{Name: "@", Text: "apex", TTL: 5 * time.Minute},
+		libdns.TXT{Name: "_acme-challenge", Text: "foo", TTL: 5 * time.Minute},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, zone.get("sub"), 1)
+	assert.Len(t, zone.get("_acme-challenge.sub"), 1)
+
+	got, err := p.GetRecords(context.TODO(), "sub.test.local.")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []libdns.Record{
+		libdns.TXT{Name: "@", Text: "apex", TTL: 5 * time.Minute},
+		libdns.TXT{Name: "_acme-challenge", Text: "foo", TTL: 5 * time.Minute},
+	}, got, "only records of the sub-zone, relative to it")
+}
+
+func TestProvider_SetRecords_fake(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	www := zone.add("www",
+		loopiaRecord{Type: "A", RData: "192.0.2.1", TTL: 300},
+		loopiaRecord{Type: "A", RData: "192.0.2.2", TTL: 300},
+		loopiaRecord{Type: "A", RData: "192.0.2.3", TTL: 300},
+		loopiaRecord{Type: "TXT", RData: "untouched", TTL: 300},
+	)
+	p := tc.getProvider()
+
+	got, err := p.SetRecords(context.TODO(), "test.local", []libdns.Record{
+		libdns.RR{Name: "www", Type: "A", Data: "192.0.2.1", TTL: 5 * time.Minute},
+		libdns.RR{Name: "www", Type: "A", Data: "192.0.2.2", TTL: time.Hour},
+		libdns.MX{Name: "@", Preference: 10, Target: "mx1.loopia.se.", TTL: time.Hour},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, got, 3)
+	assert.Equal(t, "192.0.2.2", got[1].RR().Data)
+	assert.Equal(t, time.Hour, got[1].RR().TTL)
+	assert.Equal(t, libdns.MX{Name: "@", Preference: 10, Target: "mx1.loopia.se.", TTL: time.Hour}, got[2])
+
+	assert.Equal(t, []loopiaRecord{
+		{ID: www[0], Type: "A", RData: "192.0.2.1", TTL: 300},
+		{ID: www[1], Type: "A", RData: "192.0.2.2", TTL: 3600},
+		{ID: www[3], Type: "TXT", RData: "untouched", TTL: 300},
+	}, zone.get("www"))
+	assert.Equal(t, []loopiaRecord{{ID: 1005, Type: "MX", RData: "mx1.loopia.se.", Priority: 10, TTL: 3600}}, zone.get("@"))
+	assert.True(t, zone.hasSubdomain("@"))
+	assert.Equal(t, 1, tc.callCount("updateZoneRecord"))
+	assert.Equal(t, 1, tc.callCount("removeZoneRecord"))
+
+	// a changed value reuses the existing record
+	_, err = p.SetRecords(context.TODO(), "test.local", []libdns.Record{
+		libdns.TXT{Name: "www", Text: "changed", TTL: 5 * time.Minute},
+	})
+	assert.NoError(t, err)
+	txt := zone.get("www")[2]
+	assert.Equal(t, www[3], txt.ID)
+	assert.Equal(t, "changed", txt.RData)
+	assert.Equal(t, 1, tc.callCount("addZoneRecord"))
+}
+
+func TestProvider_getRPCConcurrent(t *testing.T) {
+	p := &Provider{}
+	clients := make([]interface{}, 8)
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			clients[i] = p.getRPC()
+		}(i)
+	}
+	wg.Wait()
+	for _, c := range clients {
+		assert.Same(t, p.getRPC(), c)
+	}
+	assert.Same(t, p.getRPC(), p.ForCustomer("C1").getRPC(), "customers share the client")
+}
@@ -0,0 +1,119 @@
+package loopia
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/libdns/libdns"
+)
+
+// Customer is a customer of a Loopia reseller account.
+type Customer struct {
+	Number string `json:"customer_number"`
+	Name   string `json:"name"`
+}
+
+// GetCustomers lists the customers of a reseller account. The provider must
+// not have a Customer set.
+func (p *Provider) GetCustomers(ctx context.Context) ([]Customer, error) {
+	ctx, span := p.startSpan(ctx, "GetCustomers", "", 0)
+	unlock := p.lock("GetCustomers", true)
+	defer unlock()
+	ctx = addTrace(ctx, "GetCustomers")
+	result, err := p.getCustomers(ctx)
+	finishSpan(span, len(result), err)
+	return result, err
+}
+
+// ForCustomer returns a provider acting for the customer of a reseller
+// account. It shares the credentials, the connection and the logger, tracer,
+// metrics, middleware and audit sink set at the time of the call, but has a
+// cache of its own.
+func (p *Provider) ForCustomer(customer string) *Provider {
+	c := &Provider{
+		Username: p.Username,
+		Password: p.Password,
+		Customer: customer,
+		CacheTTL: p.CacheTTL,
+	}
+	from, to := p.state(), c.state()
+	to.rpc = p.getRPC()
+	to.logger.Store(from.logger.Load())
+	to.tracer.Store(from.tracer.Load())
+	to.metrics.Store(from.metrics.Load())
+	to.middleware.Store(from.middleware.Load())
+	to.auditSink.Store(from.auditSink.Load())
+	return c
+}
+
+// ForEachCustomer calls fn with a provider for each customer of a reseller
+// account, one at a time. It stops at the first error fn returns.
+func (p *Provider) ForEachCustomer(ctx context.Context, fn func(ctx context.Context, customer Customer, p *Provider) error) error {
+	customers, err := p.GetCustomers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, c := range customers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(ctx, c, p.ForCustomer(c.Number)); err != nil {
+			return fmt.Errorf("customer %s: %w", c.Number, err)
+		}
+	}
+	return nil
+}
+
+// ListZonesByCustomer lists the zones of every customer of a reseller
+// account, by customer number.
+func (p *Provider) ListZonesByCustomer(ctx context.Context) (map[string][]libdns.Zone, error) {
+	result := make(map[string][]libdns.Zone)
+	err := p.ForEachCustomer(ctx, func(ctx context.Context, c Customer, cp *Provider) error {
+		zones, err := cp.ListZones(ctx)
+		if err != nil {
+			return err
+		}
+		result[c.Number] = zones
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (p *Provider) getCustomers(ctx context.Context) ([]Customer, error) {
+	p.log().DebugContext(ctx, "getCustomers")
+	if p.Customer != "" {
+		return nil, errors.New("getCustomers needs a reseller provider without Customer")
+	}
+	var reply interface{}
+	if err := p.call(ctx, "getCustomers", params(), &reply); err != nil {
+		return nil, fmt.Errorf("unexpected error getting customers: %w", err)
+	}
+	if status := rpcStatus(&reply, nil); status != statusOK {
+		return nil, fmt.Errorf("unexpected error getting customers: %w", &StatusError{Method: "getCustomers", Status: status})
+	}
+	values, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected response: %v", reply)
+	}
+	result := []Customer{}
+	for _, v := range values {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected error converting customer: not a struct: %v", v)
+		}
+		c := Customer{Number: valueString(m["customer_number"]), Name: valueString(m["name"])}
+		if n, ok := m["customer_number"].(int64); ok {
+			c.Number = strconv.FormatInt(n, 10)
+		}
+		if c.Number == "" {
+			return nil, fmt.Errorf("unexpected error converting customer: no customer number: %v", v)
+		}
+		result = append(result, c)
+	}
+	return result, nil
+}
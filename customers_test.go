@@ -0,0 +1,63 @@
+package loopia
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/libdns/libdns"
+	"github.com/stretchr/testify/assert"
+)
+
+const customerValue = "<value><struct>" +
+	"<member><name>customer_number</name><value><string>%s</string></value></member>" +
+	"<member><name>name</name><value><string>%s</string></value></member>" +
+	"</struct></value>"
+
+func TestProvider_ForCustomer(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	tc.handle("getCustomers", func(t *testing.T, w http.ResponseWriter, params []string) {
+		assert.Equal(t, []string{"reseller", testSecret}, params[:2])
+		assert.Len(t, params, 2)
+		writeValue(w, "<array><data>"+
+			fmt.Sprintf(customerValue, "C1", "First AB")+
+			fmt.Sprintf(customerValue, "C2", "Second AB")+
+			"</data></array>")
+	})
+	tc.handle("getDomains", func(t *testing.T, w http.ResponseWriter, params []string) {
+		assert.Len(t, params, 3)
+		writeValue(w, "<array><data><value>"+fmt.Sprintf(domainValue, params[2]+".se")+"</value></data></array>")
+	})
+	tc.handle("addDomain", func(t *testing.T, w http.ResponseWriter, params []string) {
+		assert.Equal(t, "C1", params[2])
+		writeValue(w, stringValue("OK"))
+	})
+	p := tc.getProvider()
+	p.Username = "reseller"
+	p.Password = testSecret
+	sink := &memoryAudit{}
+	p.SetAuditSink(sink)
+
+	customers, err := p.GetCustomers(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, []Customer{{"C1", "First AB"}, {"C2", "Second AB"}}, customers)
+
+	zones, err := p.ListZonesByCustomer(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]libdns.Zone{
+		"C1": {{Name: "C1.se."}},
+		"C2": {{Name: "C2.se."}},
+	}, zones)
+
+	c := p.ForCustomer("C1")
+	assert.Equal(t, "C1", c.Customer)
+	assert.Same(t, p.getRPC(), c.getRPC())
+	assert.NoError(t, c.AddDomain(context.TODO(), "example.org"))
+	assert.Len(t, sink.entries, 1)
+	assert.Equal(t, "C1", sink.entries[0].Customer)
+
+	_, err = c.GetCustomers(context.TODO())
+	assert.Error(t, err)
+}
@@ -0,0 +1,152 @@
+package loopia
+
+import (
+	"encoding/json"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// diagnosticsSize is the number of calls kept by Diagnostics.
+const diagnosticsSize = 100
+
+// CallRecord describes one call made to the Loopia API.
+type CallRecord struct {
+	Time     time.Time     `json:"time"`
+	Method   string        `json:"method"`
+	Params   []interface{} `json:"params"`
+	Duration time.Duration `json:"duration"`
+	Status   string        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// callLog keeps the most recent calls, it is shared by copies of a provider.
+type callLog struct {
+	mu    sync.Mutex
+	calls []CallRecord
+	next  int
+}
+
+func (l *callLog) record(c CallRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.calls) < cap(l.calls) {
+		l.calls = append(l.calls, c)
+		return
+	}
+	l.calls[l.next] = c
+	l.next = (l.next + 1) % len(l.calls)
+}
+
+// Diagnostics gives access to the most recent calls a provider made to the
+// Loopia API. The parameters never include the credentials.
+type Diagnostics struct {
+	p   *Provider
+	log *callLog
+}
+
+// Diagnostics returns the diagnostics of this provider. The calls are shared
+// with copies of the provider, the configuration in Bundle is the one of p.
+func (p *Provider) Diagnostics() *Diagnostics {
+	s := p.state()
+	s.diagnosticsOnce.Do(func() {
+		s.diagnostics = &callLog{calls: make([]CallRecord, 0, diagnosticsSize)}
+	})
+	return &Diagnostics{p: p, log: s.diagnostics}
+}
+
+func (d *Diagnostics) record(c CallRecord) {
+	d.log.record(c)
+}
+
+// Calls returns the kept calls, oldest first.
+func (d *Diagnostics) Calls() []CallRecord {
+	l := d.log
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	calls := make([]CallRecord, 0, len(l.calls))
+	calls = append(calls, l.calls[l.next:]...)
+	return append(calls, l.calls[:l.next]...)
+}
+
+// DiagnosticsBundle is a snapshot of a provider to attach to support tickets.
+type DiagnosticsBundle struct {
+	Time      time.Time         `json:"time"`
+	Version   string            `json:"version"`
+	GoVersion string            `json:"go_version"`
+	Config    DiagnosticsConfig `json:"config"`
+	Calls     []CallRecord      `json:"calls"`
+}
+
+// DiagnosticsConfig is the effective configuration of a provider, without secrets.
+type DiagnosticsConfig struct {
+	Username   string        `json:"username,omitempty"`
+	Password   string        `json:"password,omitempty"`
+	Customer   string        `json:"customer,omitempty"`
+	CacheTTL   time.Duration `json:"cache_ttl,omitempty"`
+	Logging    bool          `json:"logging"`
+	Tracing    bool          `json:"tracing"`
+	Metrics    bool          `json:"metrics"`
+	Audit      bool          `json:"audit"`
+	Middleware int           `json:"middleware"`
+}
+
+// Bundle collects the kept calls together with the library version and the
+// configuration of the provider Diagnostics was called on, as it is now.
+func (d *Diagnostics) Bundle() DiagnosticsBundle {
+	p := d.p
+	c := p.redactedConfig()
+	s := p.state()
+	middleware := 0
+	if chain := s.middleware.Load(); chain != nil {
+		middleware = len(*chain)
+	}
+	return DiagnosticsBundle{
+		Time:      time.Now().UTC(),
+		Version:   libraryVersion(),
+		GoVersion: runtime.Version(),
+		Config: DiagnosticsConfig{
+			Username:   c.Username,
+			Password:   c.Password,
+			Customer:   c.Customer,
+			CacheTTL:   c.CacheTTL,
+			Logging:    s.logger.Load() != nil,
+			Tracing:    s.tracer.Load() != nil,
+			Metrics:    s.metrics.Load() != nil,
+			Audit:      s.auditSink.Load() != nil,
+			Middleware: middleware,
+		},
+		Calls: d.Calls(),
+	}
+}
+
+// WriteBundle writes the bundle as indented JSON to w.
+func (d *Diagnostics) WriteBundle(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d.Bundle())
+}
+
+const modulePath = "github.com/libdns/loopia"
+
+// libraryVersion returns the version of this module in the running binary.
+func libraryVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,77 @@
+package loopia
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiagnostics_ring(t *testing.T) {
+	p := &Provider{}
+	d := p.Diagnostics()
+	for i := 0; i < diagnosticsSize+5; i++ {
+		d.record(CallRecord{Method: fmt.Sprint(i)})
+	}
+	calls := d.Calls()
+	assert.Len(t, calls, diagnosticsSize)
+	assert.Equal(t, "5", calls[0].Method, "the oldest calls should be dropped")
+	assert.Equal(t, fmt.Sprint(diagnosticsSize+4), calls[len(calls)-1].Method)
+	assert.Equal(t, calls, p.Diagnostics().Calls(), "the calls should be kept by the provider")
+}
+
+func TestDiagnostics_Bundle_copies(t *testing.T) {
+	p := &Provider{Username: "first@loopiaapi"}
+	p.Diagnostics().record(CallRecord{Method: "getDomains"})
+	c := *p
+	c.Customer = "C1"
+	c.CacheTTL = time.Minute
+
+	bundle := c.Diagnostics().Bundle()
+	assert.Equal(t, "C1", bundle.Config.Customer)
+	assert.Equal(t, time.Minute, bundle.Config.CacheTTL)
+	assert.Len(t, bundle.Calls, 1, "copies should share the calls")
+
+	p.Username = "second@loopiaapi"
+	bundle = p.Diagnostics().Bundle()
+	assert.Equal(t, "second@loopiaapi", bundle.Config.Username)
+	assert.Empty(t, bundle.Config.Customer)
+	assert.Zero(t, bundle.Config.CacheTTL)
+}
+
+func TestDiagnostics_WriteBundle(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	tc.handle("getZoneRecords", func(t *testing.T, w http.ResponseWriter, params []string) {
+		writeFault(w, 1, "bad password "+params[1])
+	})
+	p := tc.getProvider()
+	p.Username = "user@loopiaapi"
+	p.Password = testSecret
+	p.CacheTTL = time.Minute
+
+	_, err := p.GetRecordsByName(context.TODO(), "test.local", "www")
+	assert.Error(t, err)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, p.Diagnostics().WriteBundle(buf))
+	assert.NotContains(t, buf.String(), testSecret)
+
+	bundle := DiagnosticsBundle{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &bundle))
+	assert.NotEmpty(t, bundle.Version)
+	assert.Equal(t, "user@loopiaapi", bundle.Config.Username)
+	assert.Equal(t, redacted, bundle.Config.Password)
+	assert.Equal(t, time.Minute, bundle.Config.CacheTTL)
+	assert.Len(t, bundle.Calls, 1)
+	call := bundle.Calls[0]
+	assert.Equal(t, "getZoneRecords", call.Method)
+	assert.Equal(t, []interface{}{"test.local", "www"}, call.Params)
+	assert.Equal(t, statusError, call.Status)
+	assert.Contains(t, call.Error, "bad password [REDACTED]")
+}
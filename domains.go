@@ -0,0 +1,190 @@
+package loopia
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// Domain is a domain on the Loopia account.
+type Domain struct {
+	Name string `json:"domain"`
+	// Paid is true if the domain has been paid for.
+	Paid bool `json:"paid"`
+	// Registered is true if the domain is registered through Loopia.
+	Registered bool `json:"registered"`
+	// RenewalStatus is the Loopia renewal status, like NORMAL or EXPIRED.
+	RenewalStatus string `json:"renewal_status,omitempty"`
+	// Expiration is nil if Loopia did not reply with an expiration date.
+	Expiration *time.Time `json:"expiration_date,omitempty"`
+	// ReferenceNo is the reference number to use when paying for the domain.
+	ReferenceNo int64 `json:"reference_no,omitempty"`
+}
+
+// GetDomains lists the domains on the account.
+func (p *Provider) GetDomains(ctx context.Context) ([]Domain, error) {
+	ctx, span := p.startSpan(ctx, "GetDomains", "", 0)
+	unlock := p.lock("GetDomains", true)
+	defer unlock()
+	ctx = addTrace(ctx, "GetDomains")
+	result, err := p.getDomains(ctx)
+	finishSpan(span, len(result), err)
+	return result, err
+}
+
+// GetDomain returns a single domain on the account.
+func (p *Provider) GetDomain(ctx context.Context, domain string) (*Domain, error) {
+	ctx, span := p.startSpan(ctx, "GetDomain", domain, 0)
+	unlock := p.lock("GetDomain", true)
+	defer unlock()
+	ctx = addTrace(ctx, "GetDomain")
+	result, err := p.getDomain(ctx, domain)
+	finishSpan(span, 0, err)
+	return result, err
+}
+
+// AddDomain adds a domain to the account without ordering it. Use OrderDomain
+// to buy a domain, it asks for confirmation first.
+func (p *Provider) AddDomain(ctx context.Context, domain string) error {
+	ctx, span := p.startSpan(ctx, "AddDomain", domain, 0)
+	unlock := p.lock("AddDomain", false)
+	defer unlock()
+	ctx = addTrace(ctx, "AddDomain")
+	err := p.addDomain(ctx, domain)
+	finishSpan(span, 0, err)
+	return err
+}
+
+// RemoveDomain removes a domain, and all its records, from the account. If
+// deregister is true the domain is also deregistered.
+func (p *Provider) RemoveDomain(ctx context.Context, domain string, deregister bool) error {
+	ctx, span := p.startSpan(ctx, "RemoveDomain", domain, 0)
+	unlock := p.lock("RemoveDomain", false)
+	defer unlock()
+	ctx = addTrace(ctx, "RemoveDomain")
+	err := p.removeDomain(ctx, domain, deregister)
+	finishSpan(span, 0, err)
+	return err
+}
+
+// ListZones lists the domains on the account as zones.
+func (p *Provider) ListZones(ctx context.Context) ([]libdns.Zone, error) {
+	ctx, span := p.startSpan(ctx, "ListZones", "", 0)
+	unlock := p.lock("ListZones", true)
+	defer unlock()
+	ctx = addTrace(ctx, "ListZones")
+	domains, err := p.getDomains(ctx)
+	result := []libdns.Zone{}
+	for _, d := range domains {
+		result = append(result, libdns.Zone{Name: d.Name + "."})
+	}
+	finishSpan(span, len(result), err)
+	return result, err
+}
+
+func (p *Provider) getDomains(ctx context.Context) ([]Domain, error) {
+	p.log().DebugContext(ctx, "getDomains")
+	var reply interface{}
+	if err := p.call(ctx, "getDomains", params(), &reply); err != nil {
+		return nil, fmt.Errorf("unexpected error getting domains: %w", err)
+	}
+	if status := rpcStatus(&reply, nil); status != statusOK {
+		return nil, fmt.Errorf("unexpected error getting domains: %w", &StatusError{Method: "getDomains", Status: status})
+	}
+	values, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected response: %v", reply)
+	}
+	result := []Domain{}
+	for _, v := range values {
+		d, err := toDomain(v)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected error converting domain: %w", err)
+		}
+		result = append(result, d)
+	}
+	return result, nil
+}
+
+func (p *Provider) getDomain(ctx context.Context, domain string) (*Domain, error) {
+	p.log().DebugContext(ctx, "getDomain", LogKeyZone, domain)
+	if !validZone(domain) {
+		return nil, fmt.Errorf("invalide zone '%s'", domain)
+	}
+	var reply interface{}
+	if err := p.call(ctx, "getDomain", params(cleanZone(domain)), &reply); err != nil {
+		return nil, fmt.Errorf("unexpected error getting domain: %w", err)
+	}
+	if status := rpcStatus(&reply, nil); status != statusOK {
+		return nil, fmt.Errorf("unexpected error getting domain: %w", &StatusError{Method: "getDomain", Status: status})
+	}
+	d, err := toDomain(reply)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error converting domain: %w", err)
+	}
+	return &d, nil
+}
+
+func (p *Provider) addDomain(ctx context.Context, domain string) error {
+	p.log().DebugContext(ctx, "addDomain", LogKeyZone, domain)
+	if !validZone(domain) {
+		return fmt.Errorf("invalide zone '%s'", domain)
+	}
+	domain = cleanZone(domain)
+	var response string
+	// buy is always false, orders go through OrderDomain
+	err := p.call(ctx, "addDomain", params(domain, false), &response)
+	if err == nil && response != statusOK {
+		err = fmt.Errorf("unexpected error adding domain: %w", &StatusError{Method: "addDomain", Status: response})
+	} else if err != nil {
+		err = fmt.Errorf("unexpected error adding domain: %w", err)
+	}
+	p.audit(ctx, AuditEntry{Action: AuditAddDomain, Zone: domain}, err)
+	return err
+}
+
+func (p *Provider) removeDomain(ctx context.Context, domain string, deregister bool) error {
+	p.log().DebugContext(ctx, "removeDomain", LogKeyZone, domain, "deregister", deregister)
+	if !validZone(domain) {
+		return fmt.Errorf("invalide zone '%s'", domain)
+	}
+	domain = cleanZone(domain)
+	var response string
+	err := p.call(ctx, "removeDomain", params(domain, deregister), &response)
+	p.state().cache.invalidateDomain(domain)
+	if err == nil && response != statusOK {
+		err = fmt.Errorf("unexpected error removing domain: %w", &StatusError{Method: "removeDomain", Status: response})
+	} else if err != nil {
+		err = fmt.Errorf("unexpected error removing domain: %w", err)
+	}
+	p.audit(ctx, AuditEntry{Action: AuditRemoveDomain, Zone: domain}, err)
+	return err
+}
+
+// toDomain converts a domain struct replied by Loopia.
+func toDomain(v interface{}) (Domain, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return Domain{}, fmt.Errorf("not a struct: %v", v)
+	}
+	d := Domain{
+		Name:          valueString(m["domain"]),
+		Paid:          valueBool(m["paid"]),
+		Registered:    valueBool(m["registered"]),
+		RenewalStatus: valueString(m["renewal_status"]),
+		ReferenceNo:   valueInt(m["reference_no"]),
+	}
+	if d.Name == "" {
+		return Domain{}, fmt.Errorf("domain has no name: %v", v)
+	}
+	expiration, err := valueDate(m["expiration_date"])
+	if err != nil {
+		return Domain{}, err
+	}
+	if !expiration.IsZero() {
+		d.Expiration = &expiration
+	}
+	return d, nil
+}
@@ -0,0 +1,121 @@
+package loopia
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/stretchr/testify/assert"
+)
+
+const domainValue = "<struct>" +
+	"<member><name>domain</name><value><string>%s</string></value></member>" +
+	"<member><name>paid</name><value><int>1</int></value></member>" +
+	"<member><name>registered</name><value><boolean>0</boolean></value></member>" +
+	"<member><name>renewal_status</name><value><string>NORMAL</string></value></member>" +
+	"<member><name>expiration_date</name><value><string>2030-01-31</string></value></member>" +
+	"<member><name>reference_no</name><value><int>12345</int></value></member>" +
+	"</struct>"
+
+func TestProvider_GetDomains(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	tc.handle("getDomains", func(t *testing.T, w http.ResponseWriter, params []string) {
+		assert.Len(t, params, 2)
+		writeValue(w, "<array><data><value>"+fmt.Sprintf(domainValue, "example.org")+
+			"</value><value>"+fmt.Sprintf(domainValue, "example.com")+"</value></data></array>")
+	})
+	p := tc.getProvider()
+
+	expiration := time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC)
+	domains, err := p.GetDomains(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, []Domain{{
+		Name:          "example.org",
+		Paid:          true,
+		RenewalStatus: "NORMAL",
+		Expiration:    &expiration,
+		ReferenceNo:   12345,
+	}, {
+		Name:          "example.com",
+		Paid:          true,
+		RenewalStatus: "NORMAL",
+		Expiration:    &expiration,
+		ReferenceNo:   12345,
+	}}, domains)
+
+	zones, err := p.ListZones(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, []libdns.Zone{{Name: "example.org."}, {Name: "example.com."}}, zones)
+
+	tc.handle("getDomains", func(t *testing.T, w http.ResponseWriter, params []string) {
+		writeValue(w, stringValue("AUTH_ERROR"))
+	})
+	_, err = p.GetDomains(context.TODO())
+	assert.EqualError(t, err, "unexpected error getting domains: AUTH_ERROR")
+}
+
+func TestProvider_GetDomain(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	tc.handle("getDomain", func(t *testing.T, w http.ResponseWriter, params []string) {
+		if params[2] != "example.org" {
+			writeValue(w, stringValue("UNKNOWN_ERROR"))
+			return
+		}
+		writeValue(w, fmt.Sprintf(domainValue, params[2]))
+	})
+	p := tc.getProvider()
+
+	d, err := p.GetDomain(context.TODO(), "example.org.")
+	assert.NoError(t, err)
+	assert.Equal(t, "example.org", d.Name)
+	assert.Equal(t, int64(12345), d.ReferenceNo)
+
+	_, err = p.GetDomain(context.TODO(), "example.com")
+	assert.EqualError(t, err, "unexpected error getting domain: UNKNOWN_ERROR")
+}
+
+func TestProvider_AddRemoveDomain(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	tc.handle("addDomain", func(t *testing.T, w http.ResponseWriter, params []string) {
+		assert.Equal(t, []string{"", "", "example.org", "0"}, params)
+		writeValue(w, stringValue("OK"))
+	})
+	tc.handle("removeDomain", func(t *testing.T, w http.ResponseWriter, params []string) {
+		writeValue(w, stringValue("BAD_INDATA"))
+	})
+	sink := &memoryAudit{}
+	p := tc.getProvider()
+	p.SetAuditSink(sink)
+
+	assert.NoError(t, p.AddDomain(context.TODO(), "example.org"))
+	err := p.RemoveDomain(context.TODO(), "example.org", false)
+	assert.EqualError(t, err, "unexpected error removing domain: BAD_INDATA")
+
+	assert.Len(t, sink.entries, 2)
+	assert.Equal(t, AuditAddDomain, sink.entries[0].Action)
+	assert.Equal(t, AuditSuccess, sink.entries[0].Outcome)
+	assert.Equal(t, AuditRemoveDomain, sink.entries[1].Action)
+	assert.Equal(t, AuditFailure, sink.entries[1].Outcome)
+}
+
+func Test_toDomain_expiration(t *testing.T) {
+	d, err := toDomain(map[string]interface{}{"domain": "example.org", "expiration_date": ""})
+	assert.NoError(t, err)
+	assert.Nil(t, d.Expiration)
+	data, err := json.Marshal(d)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "expiration_date", "a missing date should be left out")
+
+	d, err = toDomain(map[string]interface{}{"domain": "example.org", "expiration_date": "2030-01-31"})
+	assert.NoError(t, err)
+	data, err = json.Marshal(d)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"expiration_date":"2030-01-31T00:00:00Z"`)
+}
@@ -0,0 +1,55 @@
+package loopia
+
+import (
+	"context"
+	"sync"
+)
+
+// flight is a read in progress, shared by everyone asking for the same key.
+type flight struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+// flightGroup collapses concurrent identical reads into one API round-trip.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// do runs fn once for all concurrent callers with the same key and hands
+// each of them the result. fn gets the ctx of the first caller without its
+// cancellation, so the call is not cut short for the others. A caller whose
+// ctx is done returns early with the context error, the call itself keeps
+// running for the remaining callers.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+	f, ok := g.flights[key]
+	if !ok {
+		f = &flight{done: make(chan struct{})}
+		g.flights[key] = f
+		shared := context.WithoutCancel(ctx)
+		go func() {
+			f.val, f.err = fn(shared)
+			g.mu.Lock()
+			delete(g.flights, key)
+			g.mu.Unlock()
+			close(f.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,83 @@
+package loopia
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_flightGroup(t *testing.T) {
+	g := &flightGroup{}
+	release := make(chan struct{})
+	var calls int32
+
+	fn := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "result", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := g.do(context.TODO(), "key", fn)
+			assert.NoError(t, err)
+			assert.Equal(t, "result", v)
+		}()
+	}
+
+	// a caller that gives up should not affect the others
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	_, err := g.do(ctx, "key", fn)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	_, err = g.do(context.TODO(), "key", func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls), "finished flights should not be reused")
+}
+
+func Test_flightGroupFirstCallerCancels(t *testing.T) {
+	g := &flightGroup{}
+	started := make(chan struct{})
+	var once sync.Once
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		once.Do(func() { close(started) })
+		<-release
+		return "result", ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	first := make(chan error, 1)
+	go func() {
+		_, err := g.do(ctx, "key", fn)
+		first <- err
+	}()
+	<-started
+
+	second := make(chan error, 1)
+	go func() {
+		v, err := g.do(context.TODO(), "key", fn)
+		assert.Equal(t, "result", v)
+		second <- err
+	}()
+
+	cancel()
+	assert.ErrorIs(t, <-first, context.Canceled)
+	close(release)
+	assert.NoError(t, <-second, "the shared call should not see the first caller's cancellation")
+}
@@ -0,0 +1,180 @@
+package loopia
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Invoice is a Loopia invoice.
+type Invoice struct {
+	ReferenceNo int64  `json:"reference_no"`
+	Currency    string `json:"currency,omitempty"`
+	// Subtotal is the amount without VAT, Total the amount with VAT.
+	Subtotal Money `json:"subtotal"`
+	VAT      Money `json:"vat"`
+	Total    Money `json:"total"`
+	// ToPay is what is left to pay of Total.
+	ToPay Money         `json:"to_pay"`
+	Due   time.Time     `json:"due"`
+	Items []InvoiceItem `json:"items"`
+}
+
+// InvoiceItem is one line of an invoice.
+type InvoiceItem struct {
+	Product  string `json:"product"`
+	Domain   string `json:"domain,omitempty"`
+	Subtotal Money  `json:"subtotal"`
+	VAT      Money  `json:"vat"`
+	// From and To are the period the item is for, nil if it has none.
+	From *time.Time `json:"from,omitempty"`
+	To   *time.Time `json:"to,omitempty"`
+}
+
+// GetInvoice returns the invoice with the given reference number.
+func (p *Provider) GetInvoice(ctx context.Context, referenceNo int64) (*Invoice, error) {
+	ctx, span := p.startSpan(ctx, "GetInvoice", "", 0)
+	unlock := p.lock("GetInvoice", true)
+	defer unlock()
+	ctx = addTrace(ctx, "GetInvoice")
+	result, err := p.getInvoice(ctx, referenceNo)
+	finishSpan(span, 0, err)
+	return result, err
+}
+
+// GetCreditsAmount returns the credits on the account, with or without VAT.
+func (p *Provider) GetCreditsAmount(ctx context.Context, withVAT bool) (Money, error) {
+	ctx, span := p.startSpan(ctx, "GetCreditsAmount", "", 0)
+	unlock := p.lock("GetCreditsAmount", true)
+	defer unlock()
+	ctx = addTrace(ctx, "GetCreditsAmount")
+	result, err := p.getCreditsAmount(ctx, withVAT)
+	finishSpan(span, 0, err)
+	return result, err
+}
+
+// PayInvoiceUsingCredits pays the invoice with the given reference number
+// using the credits on the account.
+func (p *Provider) PayInvoiceUsingCredits(ctx context.Context, referenceNo int64) error {
+	ctx, span := p.startSpan(ctx, "PayInvoiceUsingCredits", "", 0)
+	unlock := p.lock("PayInvoiceUsingCredits", false)
+	defer unlock()
+	ctx = addTrace(ctx, "PayInvoiceUsingCredits")
+	err := p.payInvoiceUsingCredits(ctx, referenceNo)
+	finishSpan(span, 0, err)
+	return err
+}
+
+func (p *Provider) getInvoice(ctx context.Context, referenceNo int64) (*Invoice, error) {
+	p.log().DebugContext(ctx, "getInvoice", "reference_no", referenceNo)
+	var reply interface{}
+	if err := p.call(ctx, "getInvoice", params(referenceNo), &reply); err != nil {
+		return nil, fmt.Errorf("unexpected error getting invoice: %w", err)
+	}
+	if status := rpcStatus(&reply, nil); status != statusOK {
+		return nil, fmt.Errorf("unexpected error getting invoice: %w", &StatusError{Method: "getInvoice", Status: status})
+	}
+	invoice, err := toInvoice(reply)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error converting invoice: %w", err)
+	}
+	return invoice, nil
+}
+
+func (p *Provider) getCreditsAmount(ctx context.Context, withVAT bool) (Money, error) {
+	p.log().DebugContext(ctx, "getCreditsAmount", "with_vat", withVAT)
+	var reply interface{}
+	if err := p.call(ctx, "getCreditsAmount", params(withVAT), &reply); err != nil {
+		return 0, fmt.Errorf("unexpected error getting credits: %w", err)
+	}
+	if status := rpcStatus(&reply, nil); status != statusOK {
+		return 0, fmt.Errorf("unexpected error getting credits: %w", &StatusError{Method: "getCreditsAmount", Status: status})
+	}
+	amount, err := valueMoney(reply)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected error converting credits: %w", err)
+	}
+	return amount, nil
+}
+
+func (p *Provider) payInvoiceUsingCredits(ctx context.Context, referenceNo int64) error {
+	p.log().DebugContext(ctx, "payInvoiceUsingCredits", "reference_no", referenceNo)
+	var response string
+	err := p.call(ctx, "payInvoiceUsingCredits", params(referenceNo), &response)
+	if err == nil && response != statusOK {
+		err = fmt.Errorf("unexpected error paying invoice: %w", &StatusError{Method: "payInvoiceUsingCredits", Status: response})
+	} else if err != nil {
+		err = fmt.Errorf("unexpected error paying invoice: %w", err)
+	}
+	p.audit(ctx, AuditEntry{Action: AuditPayInvoice, ID: referenceNo}, err)
+	return err
+}
+
+// toInvoice converts an invoice struct replied by Loopia.
+func toInvoice(v interface{}) (*Invoice, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not a struct: %v", v)
+	}
+	invoice := &Invoice{
+		ReferenceNo: valueInt(m["reference_no"]),
+		Currency:    valueString(m["currency"]),
+		Items:       []InvoiceItem{},
+	}
+	var err error
+	for key, dst := range map[string]*Money{
+		"subtotal": &invoice.Subtotal,
+		"vat":      &invoice.VAT,
+		"total":    &invoice.Total,
+		"to_pay":   &invoice.ToPay,
+	} {
+		if *dst, err = valueMoney(m[key]); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	if invoice.Due, err = valueDate(m["expires"]); err != nil {
+		return nil, fmt.Errorf("expires: %w", err)
+	}
+	items, _ := m["items"].([]interface{})
+	for i, v := range items {
+		item, err := toInvoiceItem(v)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		invoice.Items = append(invoice.Items, item)
+	}
+	return invoice, nil
+}
+
+func toInvoiceItem(v interface{}) (InvoiceItem, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return InvoiceItem{}, fmt.Errorf("not a struct: %v", v)
+	}
+	item := InvoiceItem{
+		Product: valueString(m["product"]),
+		Domain:  valueString(m["domain"]),
+	}
+	var err error
+	if item.Subtotal, err = valueMoney(m["subtotal"]); err != nil {
+		return InvoiceItem{}, fmt.Errorf("subtotal: %w", err)
+	}
+	if item.VAT, err = valueMoney(m["vat"]); err != nil {
+		return InvoiceItem{}, fmt.Errorf("vat: %w", err)
+	}
+	from, err := valueDate(m["from_date"])
+	if err != nil {
+		return InvoiceItem{}, fmt.Errorf("from_date: %w", err)
+	}
+	to, err := valueDate(m["to_date"])
+	if err != nil {
+		return InvoiceItem{}, fmt.Errorf("to_date: %w", err)
+	}
+	if !from.IsZero() {
+		item.From = &from
+	}
+	if !to.IsZero() {
+		item.To = &to
+	}
+	return item, nil
+}
@@ -0,0 +1,110 @@
+package loopia
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFixture(t *testing.T, w http.ResponseWriter, name string) {
+	b, err := os.ReadFile("testdata/" + name)
+	assert.NoError(t, err)
+	fmt.Fprint(w, string(b))
+}
+
+func TestProvider_GetInvoice(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	tc.handle("getInvoice", func(t *testing.T, w http.ResponseWriter, params []string) {
+		if params[2] != "1234567" {
+			writeFixture(t, w, "error.xml")
+			return
+		}
+		writeFixture(t, w, "invoice.xml")
+	})
+	p := tc.getProvider()
+
+	invoice, err := p.GetInvoice(context.TODO(), 1234567)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1234567), invoice.ReferenceNo)
+	assert.Equal(t, "SEK", invoice.Currency)
+	assert.Equal(t, Money(31840), invoice.Subtotal)
+	assert.Equal(t, Money(7960), invoice.VAT)
+	assert.Equal(t, Money(39800), invoice.Total)
+	assert.Equal(t, Money(10), invoice.ToPay)
+	assert.Equal(t, time.Date(2026, 11, 30, 0, 0, 0, 0, time.UTC), invoice.Due)
+	assert.Len(t, invoice.Items, 2)
+	from, to := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC), time.Date(2027, 11, 30, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, InvoiceItem{
+		Product:  "Domain renewal",
+		Domain:   "example.se",
+		Subtotal: 15920,
+		VAT:      3980,
+		From:     &from,
+		To:       &to,
+	}, invoice.Items[0])
+
+	var sum Money
+	for _, item := range invoice.Items {
+		sum += item.Subtotal + item.VAT
+	}
+	assert.Equal(t, invoice.Total, sum)
+
+	_, err = p.GetInvoice(context.TODO(), 1)
+	assert.EqualError(t, err, "unexpected error getting invoice: AUTH_ERROR")
+}
+
+func TestProvider_GetCreditsAmount(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	tc.handle("getCreditsAmount", func(t *testing.T, w http.ResponseWriter, params []string) {
+		assert.Equal(t, "1", params[2])
+		writeFixture(t, w, "credits.xml")
+	})
+	p := tc.getProvider()
+
+	credits, err := p.GetCreditsAmount(context.TODO(), true)
+	assert.NoError(t, err)
+	assert.Equal(t, Money(100030), credits)
+	assert.Equal(t, "1000.30", credits.String())
+}
+
+func TestProvider_PayInvoiceUsingCredits(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	tc.handle("payInvoiceUsingCredits", func(t *testing.T, w http.ResponseWriter, params []string) {
+		if params[2] == "1234567" {
+			writeFixture(t, w, "ok.xml")
+			return
+		}
+		writeValue(w, stringValue("INSUFFICIENT_FUNDS"))
+	})
+	sink := &memoryAudit{}
+	p := tc.getProvider()
+	p.SetAuditSink(sink)
+
+	assert.NoError(t, p.PayInvoiceUsingCredits(context.TODO(), 1234567))
+	err := p.PayInvoiceUsingCredits(context.TODO(), 7654321)
+	assert.EqualError(t, err, "unexpected error paying invoice: INSUFFICIENT_FUNDS")
+
+	assert.Len(t, sink.entries, 2)
+	assert.Equal(t, AuditPayInvoice, sink.entries[0].Action)
+	assert.Equal(t, int64(1234567), sink.entries[0].ID)
+	assert.Equal(t, AuditFailure, sink.entries[1].Outcome)
+}
+
+func Test_toInvoiceItem_period(t *testing.T) {
+	item, err := toInvoiceItem(map[string]interface{}{"product": "Credits"})
+	assert.NoError(t, err)
+	assert.Nil(t, item.From)
+	assert.Nil(t, item.To)
+	data, err := json.Marshal(item)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), `"from"`, "an item without a period should leave it out")
+}
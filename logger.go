@@ -1,80 +1,150 @@
 package loopia
 
 import (
-	"sync"
+	"context"
+	"fmt"
+	"log/slog"
 )
 
-type iLogger interface {
-	Errorw(msg string, args ...interface{})
-	Errorf(format string, args ...interface{})
-	Fatalf(format string, args ...interface{})
-	Fatal(args ...interface{})
-	Infow(msg string, args ...interface{})
-	Infof(format string, args ...interface{})
-	Info(args ...interface{})
-	Warnf(format string, args ...interface{})
-	Warnw(msg string, args ...interface{})
-	Debug(args ...interface{})
-	Debugf(format string, args ...interface{})
-	Debugw(msg string, args ...interface{})
-}
+// Attribute keys used consistently in the log records of the provider.
+const (
+	LogKeyMethod   = "method"
+	LogKeyZone     = "zone"
+	LogKeyName     = "name"
+	LogKeyRecordID = "record_id"
+	LogKeyTrace    = "trace"
+)
 
-type loggerWrapper struct {
+// SetLogger sets the logger used by this provider. Debug records include the
+// API calls made, pass nil to turn logging off again.
+func (p *Provider) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		p.state().logger.Store(nil)
+		return
+	}
+	p.state().logger.Store(slog.New(&traceHandler{logger.Handler()}))
+	p.log().Info("Logging enabled")
 }
 
-func (logger *loggerWrapper) Errorw(msg string, args ...interface{}) {
-	// noop
-}
-func (logger *loggerWrapper) Errorf(format string, args ...interface{}) {
-	// noop
-}
-func (logger *loggerWrapper) Fatalf(format string, args ...interface{}) {
-	// noop
-}
-func (logger *loggerWrapper) Fatal(args ...interface{}) {
-	// noop
+var discardLogger = slog.New(discardHandler{})
+
+func (p *Provider) log() *slog.Logger {
+	if logger := p.state().logger.Load(); logger != nil {
+		return logger
+	}
+	return discardLogger
 }
-func (logger *loggerWrapper) Info(args ...interface{}) {
-	// noop
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// traceHandler adds the trace kept in the context to every record.
+type traceHandler struct {
+	slog.Handler
 }
-func (logger *loggerWrapper) Infof(format string, args ...interface{}) {
-	// noop
+
+func (h *traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if trace := getTrace(ctx); trace != "" {
+		r.AddAttrs(slog.String(LogKeyTrace, trace))
+	}
+	return h.Handler.Handle(ctx, r)
 }
-func (logger *loggerWrapper) Infow(format string, args ...interface{}) {
-	// noop
+
+func (h *traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &traceHandler{h.Handler.WithAttrs(attrs)}
 }
-func (logger *loggerWrapper) Warnf(format string, args ...interface{}) {
-	// noop
+
+func (h *traceHandler) WithGroup(name string) slog.Handler {
+	return &traceHandler{h.Handler.WithGroup(name)}
 }
-func (logger *loggerWrapper) Warnw(msg string, args ...interface{}) {
-	// noop
+
+// SugaredLogger is the part of a zap style sugared logger, like
+// *zap.SugaredLogger, that NewSugaredHandler needs.
+type SugaredLogger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
 }
-func (logger *loggerWrapper) Debug(args ...interface{}) {
-	// noop
+
+// NewSugaredHandler returns a slog.Handler that writes to a zap style sugared
+// logger. Records below level are dropped, nil means slog.LevelInfo.
+//
+//	p.SetLogger(slog.New(loopia.NewSugaredHandler(zapLogger.Sugar(), slog.LevelDebug)))
+func NewSugaredHandler(logger SugaredLogger, level slog.Leveler) slog.Handler {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return &sugaredHandler{logger: logger, level: level}
 }
-func (logger *loggerWrapper) Debugf(format string, args ...interface{}) {
-	// noop
+
+type sugaredHandler struct {
+	logger SugaredLogger
+	level  slog.Leveler
+	prefix string
+	kv     []interface{}
 }
-func (logger *loggerWrapper) Debugw(msg string, args ...interface{}) {
-	// noop
+
+func (h *sugaredHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
 }
-func (logger *loggerWrapper) Printf(format string, args ...interface{}) {
-	// noop
+
+func (h *sugaredHandler) Handle(_ context.Context, r slog.Record) error {
+	kv := append([]interface{}{}, h.kv...)
+	r.Attrs(func(a slog.Attr) bool {
+		kv = appendAttr(kv, h.prefix, a)
+		return true
+	})
+	switch {
+	case r.Level >= slog.LevelError:
+		h.logger.Errorw(r.Message, kv...)
+	case r.Level >= slog.LevelWarn:
+		h.logger.Warnw(r.Message, kv...)
+	case r.Level >= slog.LevelInfo:
+		h.logger.Infow(r.Message, kv...)
+	default:
+		h.logger.Debugw(r.Message, kv...)
+	}
+	return nil
 }
-func (logger *loggerWrapper) Println(args ...interface{}) {
-	// noop
+
+func (h *sugaredHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.kv = append([]interface{}{}, h.kv...)
+	for _, a := range attrs {
+		h2.kv = appendAttr(h2.kv, h.prefix, a)
+	}
+	return &h2
 }
 
-func Log() iLogger {
-	defaultLoggerMu.RLock()
-	defer defaultLoggerMu.RUnlock()
-	if defaultLogger == nil {
-		defaultLogger = &loggerWrapper{}
+func (h *sugaredHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
 	}
-	return defaultLogger
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
 }
 
-var (
-	defaultLogger   iLogger
-	defaultLoggerMu sync.RWMutex
-)
+// appendAttr flattens a, and any group in it, to key value pairs.
+func appendAttr(kv []interface{}, prefix string, a slog.Attr) []interface{} {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		p := prefix
+		if a.Key != "" {
+			p = fmt.Sprintf("%s%s.", prefix, a.Key)
+		}
+		for _, ga := range v.Group() {
+			kv = appendAttr(kv, p, ga)
+		}
+		return kv
+	}
+	if a.Key == "" {
+		return kv
+	}
+	return append(kv, prefix+a.Key, v.Any())
+}
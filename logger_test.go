@@ -0,0 +1,70 @@
+package loopia
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sugaredEntry struct {
+	level string
+	msg   string
+	kv    []interface{}
+}
+
+type testSugaredLogger struct {
+	entries []sugaredEntry
+}
+
+func (l *testSugaredLogger) add(level, msg string, kv []interface{}) {
+	l.entries = append(l.entries, sugaredEntry{level, msg, kv})
+}
+
+func (l *testSugaredLogger) Debugw(msg string, kv ...interface{}) { l.add("debug", msg, kv) }
+func (l *testSugaredLogger) Infow(msg string, kv ...interface{})  { l.add("info", msg, kv) }
+func (l *testSugaredLogger) Warnw(msg string, kv ...interface{})  { l.add("warn", msg, kv) }
+func (l *testSugaredLogger) Errorw(msg string, kv ...interface{}) { l.add("error", msg, kv) }
+
+func TestNewSugaredHandler(t *testing.T) {
+	sugared := &testSugaredLogger{}
+	logger := slog.New(NewSugaredHandler(sugared, slog.LevelInfo))
+
+	logger.Debug("dropped")
+	logger.Info("info", LogKeyZone, "example.org")
+	logger.With(LogKeyMethod, "getZoneRecords").WithGroup("rpc").Warn("warn", "status", "AUTH_ERROR")
+	logger.Error("error", slog.Group("record", LogKeyName, "www", LogKeyRecordID, int64(12)))
+
+	assert.Equal(t, []sugaredEntry{
+		{"info", "info", []interface{}{"zone", "example.org"}},
+		{"warn", "warn", []interface{}{"method", "getZoneRecords", "rpc.status", "AUTH_ERROR"}},
+		{"error", "error", []interface{}{"record.name", "www", "record.record_id", int64(12)}},
+	}, sugared.entries)
+}
+
+func TestProvider_SetLogger(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	buf := &bytes.Buffer{}
+	p1, p2 := tc.getProvider(), tc.getProvider()
+	p1.SetLogger(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	_, err := p2.GetRecordsByName(context.TODO(), "test.local", "www")
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "Logging enabled")
+	assert.NotContains(t, buf.String(), "called rpc", "loggers should not leak between providers")
+
+	_, err = p1.GetRecordsByName(context.TODO(), "test.local", "www")
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), fmt.Sprintf("%s=getZoneRecords", LogKeyMethod))
+	assert.Contains(t, buf.String(), fmt.Sprintf(`%s="GetRecordsByName -> lookupRecords"`, LogKeyTrace))
+
+	p1.SetLogger(nil)
+	buf.Reset()
+	_, err = p1.GetRecordsByName(context.TODO(), "test.local", "www")
+	assert.NoError(t, err)
+	assert.Empty(t, buf.String())
+}
@@ -7,6 +7,7 @@ import (
 
 // loopia does not have support for propper subdomains so
 // we need so that zone only contains <domain>.<tld>
+// The apex "@" of a zone below the domain becomes the name of that zone.
 func loopify(name, zone string) (string, string) {
 	components := strings.Split(zone, ".")
 	split := 2
@@ -14,7 +15,10 @@ func loopify(name, zone string) (string, string) {
 	if components[l-1] == "" {
 		split = 3
 	}
-	if l > split {
+	if l > split && name == "@" {
+		name = strings.Join(components[:l-split], ".")
+		zone = strings.Join(components[len(components)-split:], ".")
+	} else if l > split {
 		name = fmt.Sprintf("%s.%s", name, strings.Join(components[:l-split], "."))
 		zone = strings.Join(components[len(components)-split:], ".")
 	}
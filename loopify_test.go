@@ -31,6 +31,8 @@ func Test_loopify(t *testing.T) {
 		{"complex-blank-name", args{"", "lcl.example.org"}, ".lcl", "example.org"},
 		{"asdf", args{"", "stuff.lcl.example.org"}, ".stuff.lcl", "example.org"},
 		{"asdf", args{"some", "stuff.lcl.example.org"}, "some.stuff.lcl", "example.org"},
+		{"simple-apex", args{"@", "example.org"}, "@", "example.org"},
+		{"complex-apex", args{"@", "stuff.lcl.example.org."}, "stuff.lcl", "example.org."},
 
 		// TODO: Add test cases.
 	}
@@ -0,0 +1,63 @@
+package loopia
+
+import (
+	"time"
+)
+
+// Metrics receives measurements of how the provider uses the Loopia API.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// ObserveCall is called after every call sent to the API with the method
+	// called, the Loopia status of the call, like OK or AUTH_ERROR, and how long
+	// it took. Calls stopped by middleware before they were sent are not
+	// observed.
+	ObserveCall(method, status string, duration time.Duration)
+	// ObserveLockWait is called with how long a public method of the provider,
+	// like AppendRecords, waited for other calls to finish before it could start.
+	ObserveLockWait(method string, wait time.Duration)
+	// ObserveThrottle is called with how long an API call was held back by a
+	// rate limiter before it was sent, see Provider.Throttle.
+	ObserveThrottle(method string, delay time.Duration)
+}
+
+type metricsHolder struct {
+	metrics Metrics
+}
+
+// SetMetrics sets where the provider reports its metrics, nil turns it off.
+func (p *Provider) SetMetrics(m Metrics) {
+	if m == nil {
+		p.state().metrics.Store(nil)
+		return
+	}
+	p.state().metrics.Store(&metricsHolder{m})
+}
+
+func (p *Provider) getMetrics() Metrics {
+	if h := p.state().metrics.Load(); h != nil {
+		return h.metrics
+	}
+	return noopMetrics{}
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveCall(string, string, time.Duration) {}
+func (noopMetrics) ObserveLockWait(string, time.Duration)     {}
+func (noopMetrics) ObserveThrottle(string, time.Duration)     {}
+
+// lock takes the provider lock, shared for reads, and reports the time spent
+// waiting for it. It returns the function that releases the lock.
+func (p *Provider) lock(method string, shared bool) func() {
+	start := time.Now()
+	s := p.state()
+	unlock := s.mutex.Unlock
+	if shared {
+		s.mutex.RLock()
+		unlock = s.mutex.RUnlock
+	} else {
+		s.mutex.Lock()
+	}
+	p.getMetrics().ObserveLockWait(method, time.Since(start))
+	return unlock
+}
@@ -0,0 +1,99 @@
+package loopia
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingMetrics keeps what is observed.
+type recordingMetrics struct {
+	mu       sync.Mutex
+	calls    []string
+	duration map[string]time.Duration
+	lockWait []string
+	throttle []time.Duration
+}
+
+func (m *recordingMetrics) ObserveCall(method, status string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, method+" "+status)
+	if m.duration == nil {
+		m.duration = map[string]time.Duration{}
+	}
+	m.duration[method] = duration
+}
+
+func (m *recordingMetrics) ObserveLockWait(method string, wait time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lockWait = append(m.lockWait, method)
+}
+
+func (m *recordingMetrics) ObserveThrottle(method string, delay time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.throttle = append(m.throttle, delay)
+}
+
+func TestProvider_SetMetrics(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	tc.handle("removeZoneRecord", func(t *testing.T, w http.ResponseWriter, params []string) {
+		writeValue(w, stringValue("AUTH_ERROR"))
+	})
+	m := &recordingMetrics{}
+	p := tc.getProvider()
+	p.SetMetrics(m)
+
+	_, err := p.GetRecordsByName(context.TODO(), "test.local", "_test", "www")
+	assert.NoError(t, err)
+	_, err = p.DeleteRecords(context.TODO(), "test.local", []libdns.Record{libdns.TXT{Name: "_test"}})
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{
+		"getZoneRecords OK",
+		"getZoneRecords OK",
+		"getZoneRecords OK",
+		"removeZoneRecord AUTH_ERROR",
+	}, m.calls)
+	assert.Equal(t, []string{"GetRecordsByName", "DeleteRecords"}, m.lockWait)
+}
+
+func TestProvider_Throttle(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	m := &recordingMetrics{}
+	p := tc.getProvider()
+	p.SetMetrics(m)
+	limited := false
+	p.Use(p.Throttle(func(ctx context.Context) error {
+		if limited {
+			return errors.New("rate limited")
+		}
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}))
+
+	_, err := p.GetRecordsByName(context.TODO(), "test.local", "www")
+	assert.NoError(t, err)
+	assert.Len(t, m.throttle, 1)
+	assert.GreaterOrEqual(t, m.throttle[0], 50*time.Millisecond)
+	assert.Less(t, m.duration["getZoneRecords"], m.throttle[0], "the wait is not part of the call duration")
+
+	calls, diagnostics := len(m.calls), len(p.Diagnostics().Calls())
+	serverCalls := tc.callCount("getZoneRecords")
+	limited = true
+	_, err = p.GetRecordsByName(context.TODO(), "test.local", "_test")
+	assert.ErrorContains(t, err, "rate limited")
+	assert.Equal(t, serverCalls, tc.callCount("getZoneRecords"), "a call held back by an error is not made")
+	assert.Len(t, m.calls, calls, "a call never sent is not observed")
+	assert.Len(t, p.Diagnostics().Calls(), diagnostics, "a call never sent is not kept")
+}
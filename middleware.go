@@ -0,0 +1,65 @@
+package loopia
+
+import (
+	"context"
+	"time"
+)
+
+// Invoker makes a call to the Loopia API. The args are the arguments of the
+// API method without the credentials and customer, those are only added after
+// the last middleware and are never seen by it.
+type Invoker func(ctx context.Context, method string, args []interface{}, reply interface{}) error
+
+// Middleware wraps an Invoker to add behaviour around every call to the API.
+// It may inspect or change the arguments and reply, or return without
+// calling next at all.
+type Middleware func(next Invoker) Invoker
+
+// Use adds middleware around the calls this provider makes. Middleware runs in
+// the order added, the first one added sees every call first.
+func (p *Provider) Use(middleware ...Middleware) {
+	for {
+		old := p.state().middleware.Load()
+		chain := []Middleware{}
+		if old != nil {
+			chain = append(chain, (*old)...)
+		}
+		chain = append(chain, middleware...)
+		if p.state().middleware.CompareAndSwap(old, &chain) {
+			return
+		}
+	}
+}
+
+// invoker returns the middleware chain ending with the actual call.
+func (p *Provider) invoker() Invoker {
+	invoke := Invoker(p.send)
+	if chain := p.state().middleware.Load(); chain != nil {
+		for i := len(*chain) - 1; i >= 0; i-- {
+			invoke = (*chain)[i](invoke)
+		}
+	}
+	return invoke
+}
+
+// Throttle returns middleware that calls wait before every API call and
+// reports the time spent in it to the metrics of the provider. wait is meant
+// to be the Wait method of a rate limiter, like the one of golang.org/x/time/rate:
+//
+//	p.Use(p.Throttle(rate.NewLimiter(rate.Every(time.Second), 5).Wait))
+//
+// A call is not made if wait returns an error. The time spent waiting is not
+// part of the call duration reported to ObserveCall.
+func (p *Provider) Throttle(wait func(ctx context.Context) error) Middleware {
+	return func(next Invoker) Invoker {
+		return func(ctx context.Context, method string, args []interface{}, reply interface{}) error {
+			start := time.Now()
+			err := wait(ctx)
+			p.getMetrics().ObserveThrottle(method, time.Since(start))
+			if err != nil {
+				return err
+			}
+			return next(ctx, method, args, reply)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package loopia
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/libdns/libdns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider_Use(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	p := tc.getProvider()
+	p.Username = "user@loopiaapi"
+	p.Password = "secret"
+
+	seen := []string{}
+	trace := func(name string) Middleware {
+		return func(next Invoker) Invoker {
+			return func(ctx context.Context, method string, args []interface{}, reply interface{}) error {
+				seen = append(seen, fmt.Sprintf("%s>%s%v", name, method, args))
+				err := next(ctx, method, args, reply)
+				seen = append(seen, fmt.Sprintf("%s<%v", name, err))
+				return err
+			}
+		}
+	}
+	p.Use(trace("first"))
+	p.Use(trace("second"))
+
+	records, err := p.GetRecordsByName(context.TODO(), "test.local", "_test")
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, []string{
+		"first>getZoneRecords[test.local _test]",
+		"second>getZoneRecords[test.local _test]",
+		"second<<nil>",
+		"first<<nil>",
+	}, seen, "middleware should run in order and never see credentials")
+
+	errInjected := errors.New("injected")
+	p.Use(func(next Invoker) Invoker {
+		return func(ctx context.Context, method string, args []interface{}, reply interface{}) error {
+			if method == "addZoneRecord" {
+				return errInjected
+			}
+			return next(ctx, method, args, reply)
+		}
+	})
+	calls := tc.callCount("addZoneRecord")
+	_, err = p.AppendRecords(context.TODO(), "test.local", []libdns.Record{
+		libdns.TXT{Name: "_test", Text: "new"},
+	})
+	assert.ErrorIs(t, err, errInjected)
+	assert.Equal(t, calls, tc.callCount("addZoneRecord"), "the call should never reach the server")
+}
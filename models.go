@@ -1,12 +1,24 @@
 package loopia
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/libdns/libdns"
 )
 
+// DeleteOutcome is the result of removing one record.
+type DeleteOutcome struct {
+	// Record is the record as it was before it was removed.
+	Record libdns.Record
+	// ID is the Loopia record ID.
+	ID int64
+	// Err is set if the record could not be removed.
+	Err error
+}
+
 type loopiaRecord struct {
 	ID       int64  `xmlrpc:"record_id"`
 	TTL      int    `xmlrpc:"ttl"`
@@ -15,11 +27,25 @@ type loopiaRecord struct {
 	Priority int    `xmlrpc:"priority"`
 }
 
+// priorityFields is the number of fields in the rdata of the types Loopia
+// keeps the priority of separately.
+var priorityFields = map[string]int{
+	"MX":  1,
+	"SRV": 3,
+}
+
 func (r *loopiaRecord) libdnsRecord(subDomain string) (libdns.Record, error) {
+	data := r.RData
+	if len(data) >= 2 && strings.HasPrefix(data, `"`) && strings.HasSuffix(data, `"`) {
+		data = data[1 : len(data)-1]
+	}
+	if n, ok := priorityFields[r.Type]; ok && len(strings.Fields(data)) == n {
+		data = fmt.Sprintf("%d %s", r.Priority, data)
+	}
 	return libdns.RR{
 		Name: subDomain,
 		Type: r.Type,
-		Data: strings.Trim(r.RData, "\""),
+		Data: data,
 		TTL:  time.Duration(r.TTL) * time.Second,
 	}.Parse()
 }
@@ -41,6 +67,18 @@ func toLoopiaRecord(r libdns.Record, id int64) (loopiaRecord, error) {
 		RData: rr.Data,
 		ID:    id,
 	}
+	if n, ok := priorityFields[rr.Type]; ok {
+		fields := strings.Fields(rr.Data)
+		if len(fields) != n+1 {
+			return out, fmt.Errorf("invalid %s data '%s'", rr.Type, rr.Data)
+		}
+		priority, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return out, fmt.Errorf("invalid %s priority '%s'", rr.Type, fields[0])
+		}
+		out.Priority = priority
+		out.RData = strings.Join(fields[1:], " ")
+	}
 
 	return out, nil
 }
@@ -0,0 +1,27 @@
+package loopia
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoopiaRecord_priority(t *testing.T) {
+	mx := libdns.MX{Name: "@", Preference: 10, Target: "mx1.loopia.se.", TTL: time.Hour}
+	lr, err := toLoopiaRecord(mx, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, loopiaRecord{Type: "MX", TTL: 3600, RData: "mx1.loopia.se.", Priority: 10}, lr)
+	assert.Equal(t, mx, lr.mustLibdnsRecord("@"))
+
+	srv := libdns.SRV{Service: "sip", Transport: "tcp", Name: "@", Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.org.", TTL: time.Hour}
+	lr, err = toLoopiaRecord(srv, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "5 5060 sip.example.org.", lr.RData)
+	assert.Equal(t, 10, lr.Priority)
+	assert.Equal(t, srv, lr.mustLibdnsRecord("_sip._tcp"))
+
+	_, err = toLoopiaRecord(libdns.RR{Name: "@", Type: "MX", Data: "mx1.loopia.se."}, 0)
+	assert.Error(t, err)
+}
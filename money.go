@@ -0,0 +1,77 @@
+package loopia
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in hundredths of the currency, like öre for SEK. It is
+// kept as an integer so amounts add up without rounding.
+type Money int64
+
+// ParseMoney parses an amount like 123.45 or -0.5. More than two decimals
+// is an error rather than being rounded.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	whole, frac, _ := strings.Cut(strings.TrimPrefix(s, "-"), ".")
+	if len(frac) > 2 {
+		frac = strings.TrimRight(frac, "0")
+	}
+	if whole == "" && frac == "" || len(frac) > 2 || !digits(whole) || !digits(frac) {
+		return 0, fmt.Errorf("invalid amount '%s'", s)
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+	units, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount '%s'", s)
+	}
+	if neg {
+		units = -units
+	}
+	return Money(units), nil
+}
+
+func digits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// moneyFromFloat converts a double replied by Loopia. The shortest decimal
+// representing f is the one Loopia sent, so no rounding takes place.
+func moneyFromFloat(f float64) (Money, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid amount %v", f)
+	}
+	return ParseMoney(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// String formats the amount with two decimals, like 123.45.
+func (m Money) String() string {
+	sign, units := "", int64(m)
+	if units < 0 {
+		sign, units = "-", -units
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, units/100, units%100)
+}
+
+// MarshalJSON implements json.Marshaler, the amount is a JSON number.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (m *Money) UnmarshalJSON(b []byte) error {
+	v, err := ParseMoney(strings.Trim(string(b), `"`))
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
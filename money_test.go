@@ -0,0 +1,65 @@
+package loopia
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+		err  bool
+	}{
+		{"123.45", 12345, false},
+		{"0.1", 10, false},
+		{".5", 50, false},
+		{"-0.05", -5, false},
+		{"42", 4200, false},
+		{"1.500", 150, false},
+		{"1.005", 0, true},
+		{"--1", 0, true},
+		{"1e3", 0, true},
+		{"", 0, true},
+		{"-", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseMoney(tt.in)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMoney_noRounding(t *testing.T) {
+	var sum Money
+	for i := 0; i < 10; i++ {
+		m, err := moneyFromFloat(0.1)
+		assert.NoError(t, err)
+		sum += m
+	}
+	assert.Equal(t, Money(100), sum)
+	assert.Equal(t, "1.00", sum.String())
+
+	m, err := moneyFromFloat(1234567.89)
+	assert.NoError(t, err)
+	assert.Equal(t, Money(123456789), m)
+	assert.Equal(t, "-0.05", Money(-5).String())
+}
+
+func TestMoney_JSON(t *testing.T) {
+	b, err := json.Marshal(struct{ M Money }{12345})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"M":123.45}`, string(b))
+
+	v := struct{ M Money }{}
+	assert.NoError(t, json.Unmarshal(b, &v))
+	assert.Equal(t, Money(12345), v.M)
+}
@@ -0,0 +1,163 @@
+package loopia
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// LoopiaNameservers are the nameservers of Loopia DNS.
+var LoopiaNameservers = []string{"ns1.loopia.se", "ns2.loopia.se"}
+
+// lookupNS is replaced in tests.
+var lookupNS = net.DefaultResolver.LookupNS
+
+// NameserverOptions controls how UpdateNameservers switches nameservers.
+type NameserverOptions struct {
+	// RequireEmptyZone refuses to switch away from Loopia DNS while the zone
+	// still has records other than the NS records at the apex.
+	//
+	// UpdateNameservers never changes records, with or without this option.
+	// Records left in the Loopia zone stay there but are no longer served once
+	// the domain uses other nameservers, and are served again after switching
+	// back to LoopiaNameservers.
+	RequireEmptyZone bool
+}
+
+// GetNameservers returns the nameservers of the domain. The Loopia API can
+// not read them, so they are looked up in DNS and a recent change may not
+// show yet.
+func (p *Provider) GetNameservers(ctx context.Context, domain string) ([]string, error) {
+	ctx, span := p.startSpan(ctx, "GetNameservers", domain, 0)
+	ctx = addTrace(ctx, "GetNameservers")
+	result, err := p.getNameservers(ctx, domain)
+	finishSpan(span, len(result), err)
+	return result, err
+}
+
+// UpdateNameservers changes the nameservers of the domain, use
+// LoopiaNameservers to switch back to Loopia DNS. The hostnames are validated
+// before anything is changed.
+func (p *Provider) UpdateNameservers(ctx context.Context, domain string, nameservers []string, opts NameserverOptions) error {
+	ctx, span := p.startSpan(ctx, "UpdateNameservers", domain, len(nameservers))
+	unlock := p.lock("UpdateNameservers", false)
+	defer unlock()
+	ctx = addTrace(ctx, "UpdateNameservers")
+	err := p.updateNameservers(ctx, domain, nameservers, opts)
+	finishSpan(span, 0, err)
+	return err
+}
+
+func (p *Provider) getNameservers(ctx context.Context, domain string) ([]string, error) {
+	p.log().DebugContext(ctx, "getNameservers", LogKeyZone, domain)
+	if !validZone(domain) {
+		return nil, fmt.Errorf("invalide zone '%s'", domain)
+	}
+	found, err := lookupNS(ctx, cleanZone(domain))
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error looking up nameservers: %w", err)
+	}
+	result := []string{}
+	for _, ns := range found {
+		result = append(result, strings.ToLower(cleanZone(ns.Host)))
+	}
+	return result, nil
+}
+
+func (p *Provider) updateNameservers(ctx context.Context, domain string, nameservers []string, opts NameserverOptions) error {
+	p.log().DebugContext(ctx, "updateNameservers", LogKeyZone, domain, "nameservers", nameservers)
+	if !validZone(domain) {
+		return fmt.Errorf("invalide zone '%s'", domain)
+	}
+	domain = cleanZone(domain)
+	nameservers, err := cleanNameservers(nameservers)
+	if err != nil {
+		return err
+	}
+	if opts.RequireEmptyZone && !loopiaNameservers(nameservers) {
+		// a stale cached zone must not let the switch through
+		p.state().cache.invalidateDomain(domain)
+		records, err := p.getZoneRecords(ctx, domain)
+		if err != nil {
+			return fmt.Errorf("unexpected error getting zone records: %w", err)
+		}
+		for _, r := range records {
+			rr := r.RR()
+			if rr.Name == "@" && rr.Type == "NS" {
+				continue
+			}
+			return fmt.Errorf("refusing to switch '%s' away from Loopia DNS, it still has records", domain)
+		}
+	}
+
+	var response string
+	err = p.call(ctx, "updateDNSServers", params(domain, nameservers), &response)
+	if err == nil && response != statusOK {
+		err = fmt.Errorf("unexpected error updating nameservers: %w", &StatusError{Method: "updateDNSServers", Status: response})
+	} else if err != nil {
+		err = fmt.Errorf("unexpected error updating nameservers: %w", err)
+	}
+	p.audit(ctx, AuditEntry{
+		Action: AuditUpdateNameservers,
+		Zone:   domain,
+		After:  &AuditRecord{Type: "NS", Data: strings.Join(nameservers, " ")},
+	}, err)
+	return err
+}
+
+// cleanNameservers validates the hostnames and returns them in lower case
+// without trailing dots.
+func cleanNameservers(nameservers []string) ([]string, error) {
+	if len(nameservers) == 0 {
+		return nil, fmt.Errorf("no nameservers")
+	}
+	result := []string{}
+	seen := make(map[string]bool)
+	for _, ns := range nameservers {
+		host := strings.ToLower(cleanZone(strings.TrimSpace(ns)))
+		if !validHostname(host) {
+			return nil, fmt.Errorf("invalid nameserver '%s'", ns)
+		}
+		if seen[host] {
+			return nil, fmt.Errorf("duplicate nameserver '%s'", ns)
+		}
+		seen[host] = true
+		result = append(result, host)
+	}
+	return result, nil
+}
+
+// validHostname reports if host is a fully qualified hostname, like
+// ns1.example.org, without the trailing dot.
+func validHostname(host string) bool {
+	labels := strings.Split(host, ".")
+	if len(host) > 253 || len(labels) < 2 {
+		return false
+	}
+	for _, l := range labels {
+		if l == "" || len(l) > 63 || l[0] == '-' || l[len(l)-1] == '-' {
+			return false
+		}
+		for _, c := range l {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// loopiaNameservers reports if all nameservers are Loopia DNS.
+func loopiaNameservers(nameservers []string) bool {
+	for _, ns := range nameservers {
+		found := false
+		for _, l := range LoopiaNameservers {
+			found = found || ns == l
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package loopia
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider_GetNameservers(t *testing.T) {
+	defer func(f func(context.Context, string) ([]*net.NS, error)) { lookupNS = f }(lookupNS)
+	lookupNS = func(_ context.Context, name string) ([]*net.NS, error) {
+		assert.Equal(t, "example.org", name)
+		return []*net.NS{{Host: "NS1.Loopia.se."}, {Host: "ns2.loopia.se."}}, nil
+	}
+	p := &Provider{}
+	ns, err := p.GetNameservers(context.TODO(), "example.org.")
+	assert.NoError(t, err)
+	assert.Equal(t, LoopiaNameservers, ns)
+}
+
+func TestProvider_UpdateNameservers(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	zone.add("@", loopiaRecord{Type: "NS", RData: "ns1.loopia.se.", TTL: 3600})
+	updated := []string{}
+	tc.handle("updateDNSServers", func(t *testing.T, w http.ResponseWriter, params []string) {
+		updated = params[3:]
+		writeValue(w, stringValue("OK"))
+	})
+	sink := &memoryAudit{}
+	p := tc.getProvider()
+	p.SetAuditSink(sink)
+	// the check must not trust the zone cached by the first switch
+	p.CacheTTL = time.Hour
+	external := []string{"NS1.example.net.", "ns2.example.net"}
+
+	assert.NoError(t, p.UpdateNameservers(context.TODO(), "test.local", external, NameserverOptions{RequireEmptyZone: true}))
+	assert.Contains(t, updated, "ns1.example.net")
+	assert.Contains(t, updated, "ns2.example.net")
+	assert.Len(t, sink.entries, 1)
+	assert.Equal(t, AuditUpdateNameservers, sink.entries[0].Action)
+	assert.Equal(t, "ns1.example.net ns2.example.net", sink.entries[0].After.Data)
+
+	zone.add("www", loopiaRecord{Type: "A", RData: "127.0.0.1", TTL: 300})
+	err := p.UpdateNameservers(context.TODO(), "test.local", external, NameserverOptions{RequireEmptyZone: true})
+	assert.EqualError(t, err, "refusing to switch 'test.local' away from Loopia DNS, it still has records")
+	assert.Equal(t, 1, tc.callCount("updateDNSServers"))
+
+	assert.NoError(t, p.UpdateNameservers(context.TODO(), "test.local", LoopiaNameservers, NameserverOptions{RequireEmptyZone: true}))
+	assert.NoError(t, p.UpdateNameservers(context.TODO(), "test.local", external, NameserverOptions{}))
+	assert.Equal(t, 3, tc.callCount("updateDNSServers"))
+}
+
+func TestCleanNameservers(t *testing.T) {
+	tests := []struct {
+		in  []string
+		err string
+	}{
+		{nil, "no nameservers"},
+		{[]string{"ns1"}, "invalid nameserver 'ns1'"},
+		{[]string{"-ns1.example.org"}, "invalid nameserver '-ns1.example.org'"},
+		{[]string{"ns1.exa_mple.org"}, "invalid nameserver 'ns1.exa_mple.org'"},
+		{[]string{"ns1..example.org"}, "invalid nameserver 'ns1..example.org'"},
+		{[]string{"ns1.example.org", "NS1.example.org."}, "duplicate nameserver 'NS1.example.org.'"},
+	}
+	for _, tt := range tests {
+		_, err := cleanNameservers(tt.in)
+		assert.EqualError(t, err, tt.err)
+	}
+	ns, err := cleanNameservers([]string{" NS1.Example.org. ", "ns2.example.org"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ns1.example.org", "ns2.example.org"}, ns)
+}
+
+func TestProvider_UpdateNameserversRecordsUntouched(t *testing.T) {
+	for _, requireEmpty := range []bool{false, true} {
+		tc := setupTest(t)
+		zone := newFakeZone()
+		zone.register(tc)
+		zone.add("www", loopiaRecord{Type: "A", RData: "192.0.2.1", TTL: 300})
+		tc.handle("updateDNSServers", returnOkHandler)
+		p := tc.getProvider()
+
+		err := p.UpdateNameservers(context.TODO(), "test.local", []string{"ns1.example.net"}, NameserverOptions{RequireEmptyZone: requireEmpty})
+		if requireEmpty {
+			assert.ErrorContains(t, err, "still has records")
+			assert.Equal(t, 0, tc.callCount("updateDNSServers"))
+		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, 1, tc.callCount("updateDNSServers"))
+		}
+		assert.Len(t, zone.get("www"), 1, "records are kept either way")
+		assert.Equal(t, 0, tc.callCount("removeZoneRecord"))
+		assert.Equal(t, 0, tc.callCount("removeSubdomain"))
+		teardownTest(tc)
+	}
+}
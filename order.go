@@ -0,0 +1,126 @@
+package loopia
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Availability is the result of checking if a domain can be ordered.
+type Availability string
+
+// Availabilities of a domain.
+const (
+	DomainFree    Availability = "free"
+	DomainTaken   Availability = "taken"
+	DomainInvalid Availability = "invalid"
+	DomainError   Availability = "error"
+)
+
+// Loopia statuses of domainIsFree and orderDomain.
+const (
+	statusDomainOccupied = "DOMAIN_OCCUPIED"
+	statusBadIndata      = "BAD_INDATA"
+)
+
+// ErrOrderNotConfirmed is returned by OrderDomain when the order was not confirmed.
+var ErrOrderNotConfirmed = errors.New("order not confirmed")
+
+// OrderOptions controls how OrderDomain places an order.
+type OrderOptions struct {
+	// DryRun only checks that the domain is free, nothing is ordered.
+	DryRun bool
+	// Confirm is called before the order is placed, with the domain to
+	// order. The order is only placed if it returns true. Confirming an order
+	// accepts the terms and conditions of Loopia. Orders without Confirm are
+	// refused.
+	Confirm func(ctx context.Context, domain string) bool
+}
+
+// OrderResult is the result of OrderDomain.
+type OrderResult struct {
+	Domain       string       `json:"domain"`
+	Availability Availability `json:"availability"`
+	// Ordered is true if the order was placed.
+	Ordered bool `json:"ordered"`
+}
+
+// DomainIsFree checks if the domain can be ordered. The error is only set
+// together with DomainError.
+func (p *Provider) DomainIsFree(ctx context.Context, domain string) (Availability, error) {
+	ctx, span := p.startSpan(ctx, "DomainIsFree", domain, 0)
+	unlock := p.lock("DomainIsFree", true)
+	defer unlock()
+	ctx = addTrace(ctx, "DomainIsFree")
+	result, err := p.domainIsFree(ctx, domain)
+	finishSpan(span, 0, err)
+	return result, err
+}
+
+// OrderDomain orders the domain, which is charged for. The domain is checked
+// to be free first, and the order is only placed once opts.Confirm agrees.
+// The result is returned for dry runs and unconfirmed orders too.
+func (p *Provider) OrderDomain(ctx context.Context, domain string, opts OrderOptions) (*OrderResult, error) {
+	ctx, span := p.startSpan(ctx, "OrderDomain", domain, 0)
+	unlock := p.lock("OrderDomain", false)
+	defer unlock()
+	ctx = addTrace(ctx, "OrderDomain")
+	result, err := p.orderDomain(ctx, domain, opts)
+	finishSpan(span, 0, err)
+	return result, err
+}
+
+func (p *Provider) domainIsFree(ctx context.Context, domain string) (Availability, error) {
+	p.log().DebugContext(ctx, "domainIsFree", LogKeyZone, domain)
+	if !validZone(domain) {
+		return DomainInvalid, nil
+	}
+	var response string
+	if err := p.call(ctx, "domainIsFree", params(cleanZone(domain)), &response); err != nil {
+		return DomainError, fmt.Errorf("unexpected error checking domain: %w", err)
+	}
+	switch response {
+	case statusOK:
+		return DomainFree, nil
+	case statusDomainOccupied:
+		return DomainTaken, nil
+	case statusBadIndata:
+		return DomainInvalid, nil
+	}
+	return DomainError, fmt.Errorf("unexpected error checking domain: %w", &StatusError{Method: "domainIsFree", Status: response})
+}
+
+func (p *Provider) orderDomain(ctx context.Context, domain string, opts OrderOptions) (*OrderResult, error) {
+	domain = cleanZone(domain)
+	result := &OrderResult{Domain: domain}
+	if !opts.DryRun && opts.Confirm == nil {
+		return result, fmt.Errorf("refusing to order '%s' without confirmation", domain)
+	}
+	availability, err := p.domainIsFree(ctx, domain)
+	result.Availability = availability
+	if err != nil {
+		return result, err
+	}
+	if availability != DomainFree {
+		return result, fmt.Errorf("domain '%s' can not be ordered, it is %s", domain, availability)
+	}
+	if opts.DryRun {
+		p.log().InfoContext(ctx, "dry run, not ordering domain", LogKeyZone, domain)
+		return result, nil
+	}
+	if !opts.Confirm(ctx, domain) {
+		return result, ErrOrderNotConfirmed
+	}
+
+	p.log().InfoContext(ctx, "ordering domain", LogKeyZone, domain)
+	var response string
+	err = p.call(ctx, "orderDomain", params(domain, true), &response)
+	if err == nil && response != statusOK {
+		err = fmt.Errorf("unexpected error ordering domain: %w", &StatusError{Method: "orderDomain", Status: response})
+	} else if err != nil {
+		err = fmt.Errorf("unexpected error ordering domain: %w", err)
+	}
+	result.Ordered = err == nil
+	p.audit(ctx, AuditEntry{Action: AuditOrderDomain, Zone: domain}, err)
+	return result, err
+}
@@ -0,0 +1,100 @@
+package loopia
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider_DomainIsFree(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	statuses := map[string]string{
+		"free.se":    "OK",
+		"taken.se":   "DOMAIN_OCCUPIED",
+		"invalid.se": "BAD_INDATA",
+		"limited.se": "RATE_LIMITED",
+	}
+	tc.handle("domainIsFree", func(t *testing.T, w http.ResponseWriter, params []string) {
+		writeValue(w, stringValue(statuses[params[2]]))
+	})
+	p := tc.getProvider()
+
+	tests := []struct {
+		domain string
+		want   Availability
+		err    string
+	}{
+		{"free.se.", DomainFree, ""},
+		{"taken.se", DomainTaken, ""},
+		{"invalid.se", DomainInvalid, ""},
+		{"a.b", DomainInvalid, ""},
+		{"limited.se", DomainError, "unexpected error checking domain: RATE_LIMITED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			got, err := p.DomainIsFree(context.TODO(), tt.domain)
+			assert.Equal(t, tt.want, got)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+	assert.Equal(t, 4, tc.callCount("domainIsFree"))
+}
+
+func TestProvider_OrderDomain(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	tc.handle("domainIsFree", func(t *testing.T, w http.ResponseWriter, params []string) {
+		if params[2] == "taken.se" {
+			writeValue(w, stringValue("DOMAIN_OCCUPIED"))
+			return
+		}
+		writeValue(w, stringValue("OK"))
+	})
+	tc.handle("orderDomain", func(t *testing.T, w http.ResponseWriter, params []string) {
+		assert.Equal(t, []string{"", "", "free.se", "1"}, params)
+		writeValue(w, stringValue("OK"))
+	})
+	sink := &memoryAudit{}
+	p := tc.getProvider()
+	p.SetAuditSink(sink)
+	confirmed := []string{}
+	confirm := func(answer bool) func(context.Context, string) bool {
+		return func(_ context.Context, domain string) bool {
+			confirmed = append(confirmed, domain)
+			return answer
+		}
+	}
+
+	_, err := p.OrderDomain(context.TODO(), "free.se", OrderOptions{})
+	assert.EqualError(t, err, "refusing to order 'free.se' without confirmation")
+	assert.Equal(t, 0, tc.callCount("domainIsFree"))
+
+	result, err := p.OrderDomain(context.TODO(), "free.se", OrderOptions{DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, &OrderResult{Domain: "free.se", Availability: DomainFree}, result)
+
+	result, err = p.OrderDomain(context.TODO(), "taken.se", OrderOptions{Confirm: confirm(true)})
+	assert.EqualError(t, err, "domain 'taken.se' can not be ordered, it is taken")
+	assert.False(t, result.Ordered)
+
+	_, err = p.OrderDomain(context.TODO(), "free.se", OrderOptions{Confirm: confirm(false)})
+	assert.ErrorIs(t, err, ErrOrderNotConfirmed)
+	assert.Equal(t, 0, tc.callCount("orderDomain"))
+
+	result, err = p.OrderDomain(context.TODO(), "free.se.", OrderOptions{Confirm: confirm(true)})
+	assert.NoError(t, err)
+	assert.True(t, result.Ordered)
+	assert.Equal(t, 1, tc.callCount("orderDomain"))
+	assert.Equal(t, []string{"free.se", "free.se"}, confirmed)
+
+	assert.Len(t, sink.entries, 1)
+	assert.Equal(t, AuditOrderDomain, sink.entries[0].Action)
+	assert.Equal(t, "free.se", sink.entries[0].Zone)
+}
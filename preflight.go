@@ -0,0 +1,133 @@
+package loopia
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kolo/xmlrpc"
+)
+
+// preflightName is a subdomain name Loopia rejects, so probing the methods
+// that change a zone with it should change nothing.
+const preflightName = "loopia preflight"
+
+// PreflightOptions controls what Preflight probes.
+type PreflightOptions struct {
+	// ProbeWrites also probes the methods that change a zone, addSubdomain,
+	// removeSubdomain, addZoneRecord, updateZoneRecord and removeZoneRecord.
+	// They are called for real, with a subdomain name Loopia rejects, so
+	// nothing should change, but they are writes and are audited and counted
+	// as such by Loopia. Without it only methods that read are called.
+	ProbeWrites bool
+}
+
+// PreflightCheck is the result of probing one API method.
+type PreflightCheck struct {
+	Method string `json:"method"`
+	// Writes is true if the method changes the zone, see PreflightOptions.
+	Writes bool `json:"writes"`
+	// Allowed is true if the API user may call the method.
+	Allowed bool `json:"allowed"`
+	// Status is the Loopia status of the probe, like OK, BAD_INDATA or AUTH_ERROR.
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// PreflightReport is the result of Preflight.
+type PreflightReport struct {
+	Zone string `json:"zone"`
+	// Reachable is true if the Loopia API answered at all.
+	Reachable bool `json:"reachable"`
+	// Authenticated is true if the credentials were accepted by any method.
+	Authenticated bool `json:"authenticated"`
+	// ZoneFound is true if the subdomains of the zone could be listed.
+	ZoneFound bool             `json:"zone_found"`
+	Checks    []PreflightCheck `json:"checks"`
+}
+
+// Err returns an error describing the problems found, or nil if there were none.
+func (r *PreflightReport) Err() error {
+	switch {
+	case len(r.Checks) == 0:
+		return errors.New("no checks were made")
+	case !r.Reachable:
+		return fmt.Errorf("loopia API is not reachable: %s", r.Checks[0].Error)
+	case !r.Authenticated:
+		return errors.New("loopia API credentials are not valid")
+	case !r.ZoneFound:
+		return fmt.Errorf("zone '%s' is not found on the account", r.Zone)
+	}
+	denied := []string{}
+	for _, c := range r.Checks {
+		if !c.Allowed {
+			denied = append(denied, c.Method)
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("loopia API user may not call %s", strings.Join(denied, ", "))
+	}
+	return nil
+}
+
+// Preflight checks that the Loopia API is reachable, that the credentials
+// work, that zone belongs to the account and that the API user may call the
+// methods the provider reads with. Only methods that read are called, unless
+// opts.ProbeWrites is set. The report is always returned, the error is the one
+// from report.Err.
+func (p *Provider) Preflight(ctx context.Context, zone string, opts PreflightOptions) (*PreflightReport, error) {
+	ctx, span := p.startSpan(ctx, "Preflight", zone, 0)
+	unlock := p.lock("Preflight", !opts.ProbeWrites)
+	defer unlock()
+	ctx = addTrace(ctx, "Preflight")
+	report, err := p.preflight(ctx, zone, opts)
+	finishSpan(span, len(report.Checks), err)
+	return report, err
+}
+
+func (p *Provider) preflight(ctx context.Context, zone string, opts PreflightOptions) (*PreflightReport, error) {
+	report := &PreflightReport{Zone: cleanZone(zone)}
+	if !validZone(zone) {
+		return report, fmt.Errorf("invalide zone '%s'", zone)
+	}
+	name, domain := loopify("@", report.Zone)
+	probe := loopiaRecord{}
+	probes := []struct {
+		method string
+		writes bool
+		args   []interface{}
+	}{
+		{"getSubdomains", false, params(domain)},
+		{"getZoneRecords", false, params(domain, name)},
+		{"addSubdomain", true, params(domain, preflightName)},
+		{"removeSubdomain", true, params(domain, preflightName)},
+		{"addZoneRecord", true, params(domain, preflightName, probe)},
+		{"updateZoneRecord", true, params(domain, preflightName, probe)},
+		{"removeZoneRecord", true, params(domain, preflightName, probe.ID)},
+	}
+	for _, pr := range probes {
+		if pr.writes && !opts.ProbeWrites {
+			continue
+		}
+		var reply interface{}
+		err := p.call(ctx, pr.method, pr.args, &reply)
+		check := PreflightCheck{Method: pr.method, Writes: pr.writes, Status: rpcStatus(&reply, err)}
+		if err != nil {
+			check.Error = err.Error()
+			var fault xmlrpc.FaultError
+			report.Reachable = report.Reachable || errors.As(err, &fault)
+		} else {
+			report.Reachable = true
+			check.Allowed = check.Status != statusAuthError
+			report.Authenticated = report.Authenticated || check.Allowed
+		}
+		if pr.method == "getSubdomains" {
+			_, list := reply.([]interface{})
+			report.ZoneFound = list
+		}
+		p.log().DebugContext(ctx, "preflight", LogKeyMethod, pr.method, "writes", pr.writes, LogKeyZone, domain, "status", check.Status)
+		report.Checks = append(report.Checks, check)
+	}
+	return report, report.Err()
+}
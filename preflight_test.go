@@ -0,0 +1,79 @@
+package loopia
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider_Preflight(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	zone.add("www", loopiaRecord{Type: "A", RData: "127.0.0.1", TTL: 300})
+	p := tc.getProvider()
+
+	report, err := p.Preflight(context.TODO(), "test.local", PreflightOptions{})
+	assert.NoError(t, err)
+	assert.True(t, report.Reachable)
+	assert.True(t, report.Authenticated)
+	assert.True(t, report.ZoneFound)
+	assert.Len(t, report.Checks, 2, "only methods that read are probed by default")
+	for _, c := range report.Checks {
+		assert.True(t, c.Allowed, c.Method)
+		assert.False(t, c.Writes, c.Method)
+	}
+	for _, m := range []string{"addSubdomain", "removeSubdomain", "addZoneRecord", "updateZoneRecord", "removeZoneRecord"} {
+		assert.Equal(t, 0, tc.callCount(m), m)
+	}
+
+	report, err = p.Preflight(context.TODO(), "test.local", PreflightOptions{ProbeWrites: true})
+	assert.NoError(t, err)
+	assert.Len(t, report.Checks, 7)
+	for i, c := range report.Checks {
+		assert.True(t, c.Allowed, c.Method)
+		assert.Equal(t, i >= 2, c.Writes, c.Method)
+		if c.Writes {
+			assert.Equal(t, 1, tc.callCount(c.Method), c.Method)
+		}
+	}
+
+	tc.handle("removeZoneRecord", func(t *testing.T, w http.ResponseWriter, params []string) {
+		writeValue(w, stringValue("AUTH_ERROR"))
+	})
+	report, err = p.Preflight(context.TODO(), "test.local", PreflightOptions{ProbeWrites: true})
+	assert.EqualError(t, err, "loopia API user may not call removeZoneRecord")
+	assert.True(t, report.Authenticated)
+	assert.False(t, report.Checks[6].Allowed)
+	assert.Equal(t, "AUTH_ERROR", report.Checks[6].Status)
+}
+
+func TestProvider_Preflight_failures(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	p := tc.getProvider()
+	for _, m := range []string{"getSubdomains", "getZoneRecords", "addSubdomain", "removeSubdomain", "addZoneRecord", "updateZoneRecord", "removeZoneRecord"} {
+		tc.handle(m, func(t *testing.T, w http.ResponseWriter, params []string) {
+			writeValue(w, stringValue("AUTH_ERROR"))
+		})
+	}
+	report, err := p.Preflight(context.TODO(), "test.local", PreflightOptions{})
+	assert.EqualError(t, err, "loopia API credentials are not valid")
+	assert.True(t, report.Reachable)
+	assert.False(t, report.ZoneFound)
+
+	tc.handle("getSubdomains", func(t *testing.T, w http.ResponseWriter, params []string) {
+		writeValue(w, stringValue("UNKNOWN_ERROR"))
+	})
+	_, err = p.Preflight(context.TODO(), "test.local", PreflightOptions{})
+	assert.EqualError(t, err, "zone 'test.local' is not found on the account")
+
+	tc.server.Close()
+	report, err = p.Preflight(context.TODO(), "test.local", PreflightOptions{})
+	assert.Error(t, err)
+	assert.False(t, report.Reachable)
+	assert.Contains(t, err.Error(), "not reachable")
+}
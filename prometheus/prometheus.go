@@ -0,0 +1,94 @@
+// Package prometheus implements loopia.Metrics with Prometheus collectors. It
+// is a package of its own so that only users of it depend on client_golang,
+// import it under another name, like loopiaprom, next to client_golang.
+package prometheus
+
+import (
+	"time"
+
+	"github.com/libdns/loopia"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics implements loopia.Metrics with Prometheus collectors. Register it
+// with a prometheus.Registerer to expose the metrics.
+type Metrics struct {
+	calls    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	lockWait *prometheus.GaugeVec
+	throttle *prometheus.GaugeVec
+}
+
+// New creates the collectors, all metric names are prefixed with namespace if
+// it is not empty.
+//
+//	m := loopiaprom.New("caddy")
+//	prometheus.MustRegister(m)
+//	p.SetMetrics(m)
+func New(namespace string) *Metrics {
+	return &Metrics{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "loopia",
+			Name:      "api_calls_total",
+			Help:      "Number of calls made to the Loopia API.",
+		}, []string{"method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "loopia",
+			Name:      "api_call_duration_seconds",
+			Help:      "Duration of calls made to the Loopia API.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "status"}),
+		lockWait: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "loopia",
+			Name:      "lock_wait_seconds",
+			Help:      "Time the last call of a provider method waited for the provider lock.",
+		}, []string{"method"}),
+		throttle: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "loopia",
+			Name:      "throttle_delay_seconds",
+			Help:      "Time the last call to the Loopia API was held back by the rate limiter.",
+		}, []string{"method"}),
+	}
+}
+
+// ObserveCall implements loopia.Metrics.
+func (m *Metrics) ObserveCall(method, status string, duration time.Duration) {
+	m.calls.WithLabelValues(method, status).Inc()
+	m.duration.WithLabelValues(method, status).Observe(duration.Seconds())
+}
+
+// ObserveLockWait implements loopia.Metrics.
+func (m *Metrics) ObserveLockWait(method string, wait time.Duration) {
+	m.lockWait.WithLabelValues(method).Set(wait.Seconds())
+}
+
+// ObserveThrottle implements loopia.Metrics.
+func (m *Metrics) ObserveThrottle(method string, delay time.Duration) {
+	m.throttle.WithLabelValues(method).Set(delay.Seconds())
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.calls.Describe(ch)
+	m.duration.Describe(ch)
+	m.lockWait.Describe(ch)
+	m.throttle.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.calls.Collect(ch)
+	m.duration.Collect(ch)
+	m.lockWait.Collect(ch)
+	m.throttle.Collect(ch)
+}
+
+// Interface guards
+var (
+	_ loopia.Metrics       = (*Metrics)(nil)
+	_ prometheus.Collector = (*Metrics)(nil)
+)
@@ -0,0 +1,37 @@
+package prometheus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	m := New("test")
+	reg := prometheus.NewPedanticRegistry()
+	assert.NoError(t, reg.Register(m))
+
+	m.ObserveCall("getZoneRecords", "OK", time.Millisecond)
+	m.ObserveCall("getZoneRecords", "OK", time.Millisecond)
+	m.ObserveCall("removeZoneRecord", "AUTH_ERROR", time.Millisecond)
+	m.ObserveLockWait("DeleteRecords", time.Millisecond)
+	m.ObserveThrottle("getZoneRecords", 2*time.Second)
+	m.ObserveThrottle("getZoneRecords", time.Second)
+
+	err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP test_loopia_api_calls_total Number of calls made to the Loopia API.
+# TYPE test_loopia_api_calls_total counter
+test_loopia_api_calls_total{method="getZoneRecords",status="OK"} 2
+test_loopia_api_calls_total{method="removeZoneRecord",status="AUTH_ERROR"} 1
+# HELP test_loopia_throttle_delay_seconds Time the last call to the Loopia API was held back by the rate limiter.
+# TYPE test_loopia_throttle_delay_seconds gauge
+test_loopia_throttle_delay_seconds{method="getZoneRecords"} 1
+`), "test_loopia_api_calls_total", "test_loopia_throttle_delay_seconds")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, testutil.CollectAndCount(m, "test_loopia_api_call_duration_seconds"))
+	assert.Equal(t, 1, testutil.CollectAndCount(m, "test_loopia_lock_wait_seconds"))
+}
@@ -4,6 +4,7 @@ package loopia
 
 import (
 	"context"
+	"time"
 
 	"github.com/libdns/libdns"
 )
@@ -14,34 +15,62 @@ type Provider struct {
 	Username string `json:"username,omitempty"`
 	Password string `json:"password,omitempty"`
 	Customer string `json:"customer,omitempty"`
-	logging  bool   // Enable logging
+	// CacheTTL enables caching of subdomains and zone records for the given
+	// duration. Changes made through the provider invalidate the cache.
+	CacheTTL time.Duration `json:"cache_ttl,omitempty"`
 }
 
-func (p *Provider) SetLogger(logger iLogger) {
-	defaultLogger = logger
-	Log().Info("Logging enabled")
-	p.logging = true
+// Invalidate drops everything cached for the Loopia domain that zone belongs to.
+// Use it when the zone has been changed by other means than this provider.
+func (p *Provider) Invalidate(zone string) {
+	p.state().cache.invalidateDomain(zone)
 }
 
-// GetRecords lists all the records in the zone.
+// GetRecords lists all the records in the zone. It may run concurrently with
+// other reads, identical requests in flight are only sent once.
 func (p *Provider) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
+	ctx, span := p.startSpan(ctx, "GetRecords", zone, 0)
+	unlock := p.lock("GetRecords", true)
+	defer unlock()
 	ctx = addTrace(ctx, "GetRecords")
 	result, err := p.getZoneRecords(ctx, zone)
-	if err != nil {
-		return result, err
-	}
+	finishSpan(span, len(result), err)
 
 	return result, err
 }
 
+// GetRecordsByName lists the records with the given names, relative to the
+// zone. Unlike GetRecords it only fetches the names asked for.
+func (p *Provider) GetRecordsByName(ctx context.Context, zone string, names ...string) ([]libdns.Record, error) {
+	ctx, span := p.startSpan(ctx, "GetRecordsByName", zone, 0)
+	unlock := p.lock("GetRecordsByName", true)
+	defer unlock()
+	ctx = addTrace(ctx, "GetRecordsByName")
+	result, err := p.lookupRecords(ctx, zone, names, nil)
+	finishSpan(span, len(result), err)
+	return result, err
+}
+
+// GetRecordsByNameAndType lists the records with the given name, relative to
+// the zone, and one of the given types.
+func (p *Provider) GetRecordsByNameAndType(ctx context.Context, zone, name string, types ...string) ([]libdns.Record, error) {
+	ctx, span := p.startSpan(ctx, "GetRecordsByNameAndType", zone, 0)
+	unlock := p.lock("GetRecordsByNameAndType", true)
+	defer unlock()
+	ctx = addTrace(ctx, "GetRecordsByNameAndType")
+	result, err := p.lookupRecords(ctx, zone, []string{name}, types)
+	finishSpan(span, len(result), err)
+	return result, err
+}
+
 // AppendRecords adds records to the zone. It returns the records that were added.
 func (p *Provider) AppendRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
+	ctx, span := p.startSpan(ctx, "AppendRecords", zone, len(records))
+	unlock := p.lock("AppendRecords", false)
+	defer unlock()
 	ctx = addTrace(ctx, "AppendRecords")
 	result, err := p.addDNSEntries(ctx, zone, records)
+	finishSpan(span, len(result), err)
 
 	return result, err
 }
@@ -49,27 +78,47 @@ func (p *Provider) AppendRecords(ctx context.Context, zone string, records []lib
 // SetRecords sets the records in the zone, either by updating existing records or creating new ones.
 // It returns the updated records.
 func (p *Provider) SetRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
+	ctx, span := p.startSpan(ctx, "SetRecords", zone, len(records))
+	unlock := p.lock("SetRecords", false)
+	defer unlock()
 	ctx = addTrace(ctx, "SetRecords")
 	result, err := p.setRecords(ctx, zone, records)
+	finishSpan(span, len(result), err)
 
 	return result, err
 }
 
-// DeleteRecords deletes the records from the zone. It returns the records that were deleted.
+// DeleteRecords deletes the records from the zone. It returns the records that were deleted,
+// also when others failed, with the failures joined in the error.
 func (p *Provider) DeleteRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
+	ctx, span := p.startSpan(ctx, "DeleteRecords", zone, len(records))
+	unlock := p.lock("DeleteRecords", false)
+	defer unlock()
 	ctx = addTrace(ctx, "DeleteRecords")
 	result, err := p.deleteRecords(ctx, zone, records)
+	finishSpan(span, len(result), err)
 	return result, err
 }
 
+// DeleteRecordsWithOutcome works like DeleteRecords but returns the outcome
+// for every record that matched. Once ctx is done the remaining records are
+// not tried and get the context error as outcome. The error is only set if the
+// deletion could not be planned.
+func (p *Provider) DeleteRecordsWithOutcome(ctx context.Context, zone string, records []libdns.Record) ([]DeleteOutcome, error) {
+	ctx, span := p.startSpan(ctx, "DeleteRecordsWithOutcome", zone, len(records))
+	unlock := p.lock("DeleteRecordsWithOutcome", false)
+	defer unlock()
+	ctx = addTrace(ctx, "DeleteRecordsWithOutcome")
+	outcomes, err := p.deleteRecordsWithOutcome(ctx, zone, records)
+	finishSpan(span, len(outcomes), err)
+	return outcomes, err
+}
+
 // Interface guards
 var (
 	_ libdns.RecordGetter   = (*Provider)(nil)
 	_ libdns.RecordAppender = (*Provider)(nil)
 	_ libdns.RecordSetter   = (*Provider)(nil)
 	_ libdns.RecordDeleter  = (*Provider)(nil)
+	_ libdns.ZoneLister     = (*Provider)(nil)
 )
@@ -0,0 +1,92 @@
+package loopia
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// redacted replaces secrets in everything the provider prints.
+const redacted = "[REDACTED]"
+
+// providerJSON is the configuration of a Provider as marshalled to JSON.
+type providerJSON struct {
+	Username string        `json:"username,omitempty"`
+	Password string        `json:"password,omitempty"`
+	Customer string        `json:"customer,omitempty"`
+	CacheTTL time.Duration `json:"cache_ttl,omitempty"`
+}
+
+func (p Provider) redactedConfig() providerJSON {
+	c := providerJSON{
+		Username: p.Username,
+		Customer: p.Customer,
+		CacheTTL: p.CacheTTL,
+	}
+	if p.Password != "" {
+		c.Password = redacted
+	}
+	return c
+}
+
+// MarshalJSON implements json.Marshaler, the password is never included. The
+// methods printing a Provider have value receivers so they also apply to a
+// Provider that is not addressable.
+//
+// Like String and GoString it is promoted to structs that embed a Provider,
+// or a *Provider, so such a struct marshals and prints as the provider alone
+// and its other fields are left out. Keep the provider in a named field, or
+// give the outer struct methods of its own, to have them included.
+func (p Provider) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.redactedConfig())
+}
+
+// String implements fmt.Stringer, the password is never included.
+func (p Provider) String() string {
+	c := p.redactedConfig()
+	return fmt.Sprintf("loopia.Provider{Username: %s, Password: %s, Customer: %s, CacheTTL: %s}",
+		c.Username, c.Password, c.Customer, c.CacheTTL)
+}
+
+// GoString implements fmt.GoStringer, the password is never included.
+func (p Provider) GoString() string {
+	c := p.redactedConfig()
+	return fmt.Sprintf("loopia.Provider{Username:%q, Password:%q, Customer:%q, CacheTTL:%d}",
+		c.Username, c.Password, c.Customer, c.CacheTTL)
+}
+
+// redactedError is an error whose message had secrets removed. It does not
+// unwrap, that would give the secrets back.
+type redactedError struct {
+	msg string
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+// redactError removes the password from the message of err.
+func (p *Provider) redactError(err error) error {
+	if err == nil || p.Password == "" {
+		return err
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, p.Password) {
+		return err
+	}
+	return &redactedError{strings.ReplaceAll(msg, p.Password, redacted)}
+}
+
+// redactParams returns a copy of params with the password removed from any
+// string in it.
+func (p *Provider) redactParams(params []interface{}) []interface{} {
+	out := make([]interface{}, len(params))
+	for i, v := range params {
+		if s, ok := v.(string); ok && p.Password != "" {
+			v = strings.ReplaceAll(s, p.Password, redacted)
+		}
+		out[i] = v
+	}
+	return out
+}
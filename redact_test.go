@@ -0,0 +1,100 @@
+package loopia
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const testSecret = "s3cr3t-p4ssw0rd"
+
+func TestProvider_redaction(t *testing.T) {
+	p := &Provider{Username: "user@loopiaapi", Password: testSecret, Customer: "C123", CacheTTL: time.Minute}
+
+	b, err := json.Marshal(p)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"username":"user@loopiaapi","password":"[REDACTED]","customer":"C123","cache_ttl":60000000000}`, string(b))
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		out := fmt.Sprintf(format, p)
+		assert.NotContains(t, out, testSecret, format)
+		assert.Contains(t, out, "user@loopiaapi", format)
+	}
+
+	// values that are not addressable are redacted too
+	for name, v := range map[string]interface{}{
+		"pointer":      p,
+		"value":        *p,
+		"map of value": map[string]interface{}{"p": *p},
+		"slice":        []Provider{*p},
+		"struct":       struct{ P Provider }{*p},
+	} {
+		b, err := json.Marshal(v)
+		assert.NoError(t, err, name)
+		assert.NotContains(t, string(b), testSecret, name)
+		assert.Contains(t, string(b), `"password":"[REDACTED]"`, name)
+		for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+			out := fmt.Sprintf(format, v)
+			assert.NotContains(t, out, testSecret, name+" "+format)
+			assert.Contains(t, out, "user@loopiaapi", name+" "+format)
+		}
+	}
+
+	// the methods are promoted to structs embedding a Provider, which then
+	// marshal and print as the provider alone
+	embedded := struct {
+		*Provider
+		Zone string `json:"zone"`
+	}{p, "example.org"}
+	b, err = json.Marshal(embedded)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"username":"user@loopiaapi","password":"[REDACTED]","customer":"C123","cache_ttl":60000000000}`, string(b))
+	assert.NotContains(t, fmt.Sprintf("%v", embedded), "example.org")
+	named := struct {
+		Provider *Provider `json:"provider"`
+		Zone     string    `json:"zone"`
+	}{p, "example.org"}
+	b, err = json.Marshal(named)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"provider":{"username":"user@loopiaapi","password":"[REDACTED]","customer":"C123","cache_ttl":60000000000},"zone":"example.org"}`, string(b))
+
+	b, err = json.Marshal(&Provider{Username: "user@loopiaapi"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"username":"user@loopiaapi"}`, string(b), "an empty password should stay empty")
+}
+
+func TestProvider_redactedOutput(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	tc.handle("getZoneRecords", func(t *testing.T, w http.ResponseWriter, params []string) {
+		writeFault(w, 403, fmt.Sprintf("denied for %s", strings.Join(params, ",")))
+	})
+	buf := &bytes.Buffer{}
+	exporter := tracetest.NewInMemoryExporter()
+	p := tc.getProvider()
+	p.Username = "user@loopiaapi"
+	p.Password = testSecret
+	p.SetLogger(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	p.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	_, err := p.GetRecordsByName(context.TODO(), "test.local", "www")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "denied for user@loopiaapi,[REDACTED],test.local,www")
+	assert.NotContains(t, err.Error(), testSecret)
+	assert.NotContains(t, buf.String(), testSecret)
+
+	spans, err := json.Marshal(exporter.GetSpans())
+	assert.NoError(t, err)
+	assert.Contains(t, string(spans), "denied for")
+	assert.NotContains(t, string(spans), testSecret)
+}
@@ -0,0 +1,84 @@
+package loopia
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kolo/xmlrpc"
+)
+
+// Loopia statuses meaning a call failed, whatever the method.
+var failureStatuses = map[string]bool{
+	statusAuthError: true,
+	"UNKNOWN_ERROR": true,
+	"RATE_LIMITED":  true,
+	statusBadIndata: true,
+}
+
+// StatusError is a status replied by Loopia in place of OK or the data asked
+// for, like AUTH_ERROR.
+type StatusError struct {
+	Method string
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return e.Status
+}
+
+// Is makes errors.Is match a StatusError with the same status, and the same
+// method if target has one.
+func (e *StatusError) Is(target error) bool {
+	t, ok := target.(*StatusError)
+	if !ok {
+		return false
+	}
+	return t.Status == e.Status && (t.Method == "" || t.Method == e.Method)
+}
+
+// Call calls a method of the Loopia API that the provider does not wrap. The
+// credentials and customer are added in front of args, and reply is decoded
+// like in xmlrpc.Client.Call, whatever its type. The call is logged, traced,
+// measured and goes through the middleware like any other. Statuses like
+// AUTH_ERROR, replied in place of the result, are returned as a *StatusError.
+// Calls are not retried, like the internal ones, add middleware for that.
+//
+// Changes made through Call are not known to the cache, use Invalidate.
+func (p *Provider) Call(ctx context.Context, method string, args []interface{}, reply interface{}) error {
+	ctx, span := p.startSpan(ctx, "Call", "", 0)
+	unlock := p.lock("Call", false)
+	defer unlock()
+	ctx = addTrace(ctx, "Call")
+	// the status is only found in a reply decoded without a type
+	var raw interface{}
+	err := p.call(ctx, method, args, &raw)
+	if err != nil {
+		err = fmt.Errorf("unexpected error calling %s: %w", method, err)
+	} else if status := rpcStatus(&raw, nil); failureStatuses[status] {
+		err = fmt.Errorf("unexpected error calling %s: %w", method, &StatusError{Method: method, Status: status})
+	} else if err = decodeReply(raw, reply); err != nil {
+		err = fmt.Errorf("unexpected error decoding reply of %s: %w", method, err)
+	}
+	finishSpan(span, 0, err)
+	return err
+}
+
+// decodeReply stores raw, a reply decoded into an interface{}, in reply. It is
+// encoded again and decoded into reply like xmlrpc.Client.Call would have.
+func decodeReply(raw interface{}, reply interface{}) error {
+	switch r := reply.(type) {
+	case nil:
+		return nil
+	case *interface{}:
+		*r = raw
+		return nil
+	}
+	if raw == nil {
+		return nil
+	}
+	data, err := xmlrpc.EncodeMethodCall("reply", raw)
+	if err != nil {
+		return err
+	}
+	return xmlrpc.Response(data).Unmarshal(reply)
+}
@@ -0,0 +1,94 @@
+package loopia
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider_Call(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	tc.handle("getDomainAuthCode", func(t *testing.T, w http.ResponseWriter, params []string) {
+		assert.Equal(t, []string{"user", testSecret, "C1", "example.org"}, params)
+		writeValue(w, stringValue("secret-code"))
+	})
+	tc.handle("transferDomain", func(t *testing.T, w http.ResponseWriter, params []string) {
+		writeValue(w, stringValue("AUTH_ERROR"))
+	})
+	p := tc.getProvider()
+	p.Username, p.Password, p.Customer = "user", testSecret, "C1"
+	var seen []string
+	p.Use(func(next Invoker) Invoker {
+		return func(ctx context.Context, method string, args []interface{}, reply interface{}) error {
+			seen = append(seen, method)
+			return next(ctx, method, args, reply)
+		}
+	})
+
+	var code string
+	assert.NoError(t, p.Call(context.TODO(), "getDomainAuthCode", params("example.org"), &code))
+	assert.Equal(t, "secret-code", code)
+
+	var status interface{}
+	err := p.Call(context.TODO(), "transferDomain", params("example.org"), &status)
+	assert.EqualError(t, err, "unexpected error calling transferDomain: AUTH_ERROR")
+	statusErr := &StatusError{}
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, "transferDomain", statusErr.Method)
+	assert.ErrorIs(t, err, &StatusError{Status: "AUTH_ERROR"})
+	assert.NotErrorIs(t, err, &StatusError{Method: "getDomain", Status: "AUTH_ERROR"})
+
+	assert.Equal(t, []string{"getDomainAuthCode", "transferDomain"}, seen)
+	assert.Len(t, p.Diagnostics().Calls(), 2)
+}
+
+func TestProvider_Call_typedReply(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	tc.handle("getZoneRecords", func(t *testing.T, w http.ResponseWriter, params []string) {
+		if params[3] == "www" {
+			writeValue(w, recordsValue([]loopiaRecord{{ID: 7, TTL: 300, Type: "A", RData: "192.0.2.1"}}))
+			return
+		}
+		writeValue(w, stringValue("AUTH_ERROR"))
+	})
+	tc.handle("getCreditsAmount", func(t *testing.T, w http.ResponseWriter, params []string) {
+		writeValue(w, stringValue("RATE_LIMITED"))
+	})
+	p := tc.getProvider()
+
+	var records []loopiaRecord
+	assert.NoError(t, p.Call(context.TODO(), "getZoneRecords", params("test.local", "www"), &records))
+	assert.Equal(t, []loopiaRecord{{ID: 7, TTL: 300, Type: "A", RData: "192.0.2.1"}}, records)
+
+	records = nil
+	err := p.Call(context.TODO(), "getZoneRecords", params("test.local", "_test"), &records)
+	assert.ErrorIs(t, err, &StatusError{Method: "getZoneRecords", Status: "AUTH_ERROR"})
+	assert.Nil(t, records)
+
+	var amount float64
+	err = p.Call(context.TODO(), "getCreditsAmount", params(), &amount)
+	assert.ErrorIs(t, err, &StatusError{Method: "getCreditsAmount", Status: "RATE_LIMITED"})
+
+	var record struct {
+		ID int64 `xmlrpc:"record_id"`
+	}
+	err = p.Call(context.TODO(), "getZoneRecords", params("test.local", "www"), &record)
+	assert.ErrorContains(t, err, "unexpected error decoding reply of getZoneRecords")
+}
+
+func TestStatusError_internal(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	tc.handle("removeDomain", func(t *testing.T, w http.ResponseWriter, params []string) {
+		writeValue(w, stringValue("RATE_LIMITED"))
+	})
+	p := tc.getProvider()
+	err := p.RemoveDomain(context.TODO(), "example.org", false)
+	assert.EqualError(t, err, "unexpected error removing domain: RATE_LIMITED")
+	assert.ErrorIs(t, err, &StatusError{Method: "removeDomain", Status: "RATE_LIMITED"})
+}
@@ -2,11 +2,15 @@ package loopia
 
 import (
 	"fmt"
+	"html"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/kolo/xmlrpc"
@@ -36,20 +40,56 @@ type testContext struct {
 
 	rpc    *xmlrpc.Client
 	server *httptest.Server
+
+	mu       sync.Mutex
+	calls    map[string]int
+	handlers map[string]methodHandler
+}
+
+// handle overrides the handler of method for this test context only.
+func (tc *testContext) handle(method string, h methodHandler) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.handlers == nil {
+		tc.handlers = make(map[string]methodHandler)
+	}
+	tc.handlers[method] = h
+}
+
+func (tc *testContext) handler(method string) methodHandler {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if h := tc.handlers[method]; h != nil {
+		return h
+	}
+	return handlers[method]
+}
+
+// callCount returns how many times method has been called on the test server.
+func (tc *testContext) callCount(method string) int {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return tc.calls[method]
+}
+
+func (tc *testContext) countCall(method string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.calls[method]++
 }
 
 func (tc *testContext) getProvider() *Provider {
 	p := &Provider{}
-	p.rpc = tc.rpc
+	p.state().rpc = tc.rpc
 	return p
 }
 
 func setupTest(t *testing.T) *testContext {
-	tc := &testContext{}
+	tc := &testContext{calls: make(map[string]int)}
 	tc.mux = http.NewServeMux()
 	tc.server = httptest.NewServer(tc.mux)
 	tc.rpc, _ = xmlrpc.NewClient(tc.server.URL, nil)
-	tc.mux.HandleFunc("/", apiHandler(t))
+	tc.mux.HandleFunc("/", apiHandler(t, tc))
 	return tc
 }
 
@@ -59,7 +99,7 @@ func teardownTest(tc *testContext) {
 	}
 }
 
-func apiHandler(t *testing.T) func(w http.ResponseWriter, r *http.Request) {
+func apiHandler(t *testing.T, tc *testContext) func(w http.ResponseWriter, r *http.Request) {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, r.Method, "POST")
@@ -70,6 +110,7 @@ func apiHandler(t *testing.T) func(w http.ResponseWriter, r *http.Request) {
 		root := doc.Root
 
 		method := root.GetChild("methodName").Text
+		tc.countCall(method)
 		params := root.GetChild("params")
 		values := params.Query("//value")
 
@@ -78,7 +119,7 @@ func apiHandler(t *testing.T) func(w http.ResponseWriter, r *http.Request) {
 			strValues = append(strValues, v.FirstChild().Text)
 		}
 
-		h := handlers[method]
+		h := tc.handler(method)
 		if h != nil {
 			h(t, w, strValues)
 			return
@@ -133,3 +174,169 @@ func returnOkHandler(t *testing.T, w http.ResponseWriter, params []string) {
 	byteArray, _ := os.ReadFile("testdata/ok.xml")
 	fmt.Fprint(w, string(byteArray[:]))
 }
+
+// writeValue writes a response with value as the single xml-rpc value.
+func writeValue(w http.ResponseWriter, value string) {
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value>%s</value></param></params></methodResponse>`, value)
+}
+
+// writeFault writes an xml-rpc fault response.
+func writeFault(w http.ResponseWriter, code int, msg string) {
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><fault><value><struct>`+
+		`<member><name>faultCode</name><value><int>%d</int></value></member>`+
+		`<member><name>faultString</name><value>%s</value></member>`+
+		`</struct></value></fault></methodResponse>`, code, stringValue(msg))
+}
+
+func stringValue(s string) string {
+	return fmt.Sprintf("<string>%s</string>", html.EscapeString(s))
+}
+
+func stringsValue(values []string) string {
+	b := strings.Builder{}
+	b.WriteString("<array><data>")
+	for _, v := range values {
+		fmt.Fprintf(&b, "<value>%s</value>", stringValue(v))
+	}
+	b.WriteString("</data></array>")
+	return b.String()
+}
+
+func recordsValue(records []loopiaRecord) string {
+	b := strings.Builder{}
+	b.WriteString("<array><data>")
+	for _, r := range records {
+		fmt.Fprintf(&b, "<value><struct>"+
+			"<member><name>record_id</name><value><int>%d</int></value></member>"+
+			"<member><name>ttl</name><value><int>%d</int></value></member>"+
+			"<member><name>type</name><value>%s</value></member>"+
+			"<member><name>rdata</name><value>%s</value></member>"+
+			"<member><name>priority</name><value><int>%d</int></value></member>"+
+			"</struct></value>", r.ID, r.TTL, stringValue(r.Type), stringValue(r.RData), r.Priority)
+	}
+	b.WriteString("</data></array>")
+	return b.String()
+}
+
+// fakeZone is a stateful stand-in for the records of one Loopia domain.
+// Credentials are expected as the first two parameters and no customer.
+type fakeZone struct {
+	mu         sync.Mutex
+	nextID     int64
+	subdomains []string
+	records    map[string][]loopiaRecord
+}
+
+func newFakeZone() *fakeZone {
+	return &fakeZone{nextID: 1000, records: make(map[string][]loopiaRecord)}
+}
+
+// add puts records directly into the zone and returns the IDs they got.
+func (z *fakeZone) add(name string, records ...loopiaRecord) []int64 {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.addSubdomain(name)
+	ids := []int64{}
+	for _, r := range records {
+		z.nextID++
+		r.ID = z.nextID
+		z.records[name] = append(z.records[name], r)
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func (z *fakeZone) get(name string) []loopiaRecord {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return append([]loopiaRecord{}, z.records[name]...)
+}
+
+func (z *fakeZone) hasSubdomain(name string) bool {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	for _, s := range z.subdomains {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (z *fakeZone) addSubdomain(name string) {
+	for _, s := range z.subdomains {
+		if s == name {
+			return
+		}
+	}
+	z.subdomains = append(z.subdomains, name)
+}
+
+// recordParam reads a record struct flattened at the end of params.
+func recordParam(params []string) loopiaRecord {
+	l := len(params)
+	id, _ := strconv.ParseInt(params[l-5], 10, 64)
+	ttl, _ := strconv.Atoi(params[l-4])
+	prio, _ := strconv.Atoi(params[l-1])
+	return loopiaRecord{ID: id, TTL: ttl, Type: params[l-3], RData: params[l-2], Priority: prio}
+}
+
+func (z *fakeZone) register(tc *testContext) {
+	tc.handle("getSubdomains", func(t *testing.T, w http.ResponseWriter, params []string) {
+		z.mu.Lock()
+		defer z.mu.Unlock()
+		writeValue(w, stringsValue(z.subdomains))
+	})
+	tc.handle("getZoneRecords", func(t *testing.T, w http.ResponseWriter, params []string) {
+		writeValue(w, recordsValue(z.get(params[3])))
+	})
+	tc.handle("addSubdomain", func(t *testing.T, w http.ResponseWriter, params []string) {
+		z.mu.Lock()
+		defer z.mu.Unlock()
+		z.addSubdomain(params[3])
+		writeValue(w, stringValue("OK"))
+	})
+	tc.handle("removeSubdomain", func(t *testing.T, w http.ResponseWriter, params []string) {
+		z.mu.Lock()
+		defer z.mu.Unlock()
+		for i, s := range z.subdomains {
+			if s == params[3] {
+				z.subdomains = append(z.subdomains[:i], z.subdomains[i+1:]...)
+				break
+			}
+		}
+		delete(z.records, params[3])
+		writeValue(w, stringValue("OK"))
+	})
+	tc.handle("addZoneRecord", func(t *testing.T, w http.ResponseWriter, params []string) {
+		z.add(params[3], recordParam(params))
+		writeValue(w, stringValue("OK"))
+	})
+	tc.handle("updateZoneRecord", func(t *testing.T, w http.ResponseWriter, params []string) {
+		z.mu.Lock()
+		defer z.mu.Unlock()
+		updated := recordParam(params)
+		for i, r := range z.records[params[3]] {
+			if r.ID == updated.ID {
+				z.records[params[3]][i] = updated
+				writeValue(w, stringValue("OK"))
+				return
+			}
+		}
+		writeValue(w, stringValue("UNKNOWN_ERROR"))
+	})
+	tc.handle("removeZoneRecord", func(t *testing.T, w http.ResponseWriter, params []string) {
+		z.mu.Lock()
+		defer z.mu.Unlock()
+		id, _ := strconv.ParseInt(params[4], 10, 64)
+		records := z.records[params[3]]
+		for i, r := range records {
+			if r.ID == id {
+				z.records[params[3]] = append(records[:i:i], records[i+1:]...)
+				writeValue(w, stringValue("OK"))
+				return
+			}
+		}
+		writeValue(w, stringValue("UNKNOWN_ERROR"))
+	})
+}
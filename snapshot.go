@@ -0,0 +1,326 @@
+package loopia
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// snapshotVersion is the version of the snapshot format.
+const snapshotVersion = 1
+
+// snapshotTime names snapshot files so they sort by the time taken.
+const snapshotTime = "20060102T150405.000000000Z"
+
+// Snapshot is the state of a zone at Loopia at a point in time.
+type Snapshot struct {
+	Version int       `json:"version"`
+	Zone    string    `json:"zone"`
+	Created time.Time `json:"created"`
+	// Subdomains are relative to the zone, "@" for the apex.
+	Subdomains []string         `json:"subdomains"`
+	Records    []SnapshotRecord `json:"records"`
+}
+
+// SnapshotRecord is a record in a Snapshot.
+type SnapshotRecord struct {
+	// ID is the Loopia ID the record had.
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Data string `json:"data"`
+	// TTL in seconds.
+	TTL int `json:"ttl"`
+}
+
+// LibdnsRecords returns the records of the snapshot.
+func (s *Snapshot) LibdnsRecords() ([]libdns.Record, error) {
+	records := []libdns.Record{}
+	for _, r := range s.Records {
+		record, err := libdns.RR{
+			Name: r.Name,
+			Type: r.Type,
+			Data: r.Data,
+			TTL:  time.Duration(r.TTL) * time.Second,
+		}.Parse()
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", r.ID, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// SnapshotStore keeps snapshots as JSON files in a directory, one
+// subdirectory per zone, with every version saved.
+type SnapshotStore struct {
+	Dir string
+}
+
+// zoneDir returns the directory of the snapshots of zone. The zone must be a
+// single path element, so no snapshot is read or written outside Dir.
+func (s SnapshotStore) zoneDir(zone string) (string, error) {
+	zone = cleanZone(zone)
+	if !validZone(zone) || strings.ContainsAny(zone, `/\`) || strings.HasPrefix(zone, ".") || !filepath.IsLocal(zone) {
+		return "", fmt.Errorf("invalide zone '%s'", zone)
+	}
+	return filepath.Join(s.Dir, zone), nil
+}
+
+// Save writes the snapshot and returns its version. An existing snapshot is
+// never overwritten.
+func (s SnapshotStore) Save(snapshot *Snapshot) (string, error) {
+	dir, err := s.zoneDir(snapshot.Zone)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	version := snapshot.Created.UTC().Format(snapshotTime)
+	f, err := os.CreateTemp(dir, ".snapshot-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(snapshot); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	// a link fails if the version exists, where a rename would replace it
+	path := filepath.Join(dir, version+".json")
+	if err := os.Link(f.Name(), path); os.IsExist(err) {
+		return "", fmt.Errorf("snapshot %s of %s already exists", version, cleanZone(snapshot.Zone))
+	} else if err != nil {
+		return "", err
+	}
+	return version, nil
+}
+
+// Versions returns the versions of the snapshots of zone, oldest first.
+func (s SnapshotStore) Versions(zone string) ([]string, error) {
+	dir, err := s.zoneDir(zone)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	versions := []string{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.Type().IsRegular() && !strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".json") {
+			versions = append(versions, strings.TrimSuffix(name, ".json"))
+		}
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// Load reads a version of the snapshots of zone.
+func (s SnapshotStore) Load(zone, version string) (*Snapshot, error) {
+	if version == "" || strings.ContainsAny(version, `/\`) || strings.HasPrefix(version, ".") {
+		return nil, fmt.Errorf("invalid snapshot version '%s'", version)
+	}
+	dir, err := s.zoneDir(zone)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, version+".json"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadSnapshot(f)
+}
+
+// Latest reads the newest snapshot of zone.
+func (s SnapshotStore) Latest(zone string) (*Snapshot, error) {
+	versions, err := s.Versions(zone)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no snapshots of %s", cleanZone(zone))
+	}
+	return s.Load(zone, versions[len(versions)-1])
+}
+
+// ReadSnapshot reads a snapshot saved by SnapshotStore.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	snapshot := &Snapshot{}
+	if err := json.NewDecoder(r).Decode(snapshot); err != nil {
+		return nil, err
+	}
+	if snapshot.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+	return snapshot, nil
+}
+
+// Snapshot returns the subdomains and records of zone.
+func (p *Provider) Snapshot(ctx context.Context, zone string) (*Snapshot, error) {
+	ctx, span := p.startSpan(ctx, "Snapshot", zone, 0)
+	unlock := p.lock("Snapshot", true)
+	defer unlock()
+	ctx = addTrace(ctx, "Snapshot")
+	snapshot, err := p.snapshot(ctx, zone)
+	count := 0
+	if snapshot != nil {
+		count = len(snapshot.Records)
+	}
+	finishSpan(span, count, err)
+	return snapshot, err
+}
+
+// SnapshotZone takes a snapshot of zone, saves it in store and returns its
+// version.
+func (p *Provider) SnapshotZone(ctx context.Context, zone string, store SnapshotStore) (string, error) {
+	snapshot, err := p.Snapshot(ctx, zone)
+	if err != nil {
+		return "", err
+	}
+	return store.Save(snapshot)
+}
+
+// SnapshotAccount saves a snapshot of every domain of the account in store
+// and returns their versions by zone. It stops at the first failure,
+// returning the versions saved so far.
+func (p *Provider) SnapshotAccount(ctx context.Context, store SnapshotStore) (map[string]string, error) {
+	versions := make(map[string]string)
+	domains, err := p.GetDomains(ctx)
+	if err != nil {
+		return versions, err
+	}
+	for _, d := range domains {
+		version, err := p.SnapshotZone(ctx, d.Name, store)
+		if err != nil {
+			return versions, fmt.Errorf("unexpected error taking snapshot of %s: %w", d.Name, err)
+		}
+		versions[d.Name] = version
+	}
+	return versions, nil
+}
+
+// PlanRestore returns the changes that would restore the records of the
+// snapshot, see Plan.
+func (p *Provider) PlanRestore(ctx context.Context, snapshot *Snapshot, opts PlanOptions) (*Plan, error) {
+	records, err := snapshot.LibdnsRecords()
+	if err != nil {
+		return nil, err
+	}
+	return p.Plan(ctx, snapshot.Zone, records, opts)
+}
+
+// Restore makes the zone of the snapshot match it again with as few changes
+// as possible, applying them like Apply, and returns the plan it applied.
+// Subdomains of the snapshot are added back and empty subdomains not in it
+// are removed. Records that are created again get new Loopia IDs.
+func (p *Provider) Restore(ctx context.Context, snapshot *Snapshot, opts PlanOptions, progress ApplyProgress) (*Plan, error) {
+	ctx, span := p.startSpan(ctx, "Restore", snapshot.Zone, len(snapshot.Records))
+	unlock := p.lock("Restore", false)
+	defer unlock()
+	ctx = addTrace(ctx, "Restore")
+	plan, err := p.restore(ctx, snapshot, opts, progress)
+	changes := 0
+	if plan != nil {
+		changes = len(plan.Changes)
+	}
+	finishSpan(span, changes, err)
+	return plan, err
+}
+
+func (p *Provider) snapshot(ctx context.Context, zone string) (*Snapshot, error) {
+	p.log().DebugContext(ctx, "snapshot", LogKeyZone, zone)
+	if !validZone(zone) {
+		return nil, fmt.Errorf("invalide zone '%s'", zone)
+	}
+	zone = cleanZone(zone)
+	names, entries, err := p.zoneEntries(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{
+		Version:    snapshotVersion,
+		Zone:       zone,
+		Created:    time.Now().UTC(),
+		Subdomains: append([]string{}, names...),
+		Records:    []SnapshotRecord{},
+	}
+	for _, e := range entries {
+		snapshot.Records = append(snapshot.Records, SnapshotRecord{
+			ID:   e.id,
+			Name: planName(e.record.Name),
+			Type: e.record.Type,
+			Data: e.record.Data,
+			TTL:  int(e.record.TTL / time.Second),
+		})
+	}
+	return snapshot, nil
+}
+
+func (p *Provider) restore(ctx context.Context, snapshot *Snapshot, opts PlanOptions, progress ApplyProgress) (*Plan, error) {
+	p.log().DebugContext(ctx, "restore", LogKeyZone, snapshot.Zone, "created", snapshot.Created)
+	records, err := snapshot.LibdnsRecords()
+	if err != nil {
+		return nil, err
+	}
+	plan, err := p.plan(ctx, snapshot.Zone, records, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.apply(ctx, plan, progress); err != nil {
+		return plan, err
+	}
+
+	zone := plan.Zone
+	names, entries, err := p.zoneEntries(ctx, zone)
+	if err != nil {
+		return plan, err
+	}
+	live := make(map[string]bool)
+	for _, name := range names {
+		live[name] = true
+	}
+	used := make(map[string]bool)
+	for _, e := range entries {
+		used[planName(e.record.Name)] = true
+	}
+	wanted := make(map[string]bool)
+	for _, name := range snapshot.Subdomains {
+		wanted[name] = true
+		if live[name] || opts.ignored(name, "") {
+			continue
+		}
+		n, z := loopify(name, zone)
+		if err := p.addSubdomain(ctx, z, n); err != nil {
+			return plan, fmt.Errorf("unexpected error restoring subdomain %s: %w", name, err)
+		}
+	}
+	for _, name := range names {
+		if wanted[name] || used[name] || name == "@" || opts.ignored(name, "") {
+			continue
+		}
+		n, z := loopify(name, zone)
+		if err := p.removeSubdomain(ctx, z, n); err != nil {
+			return plan, fmt.Errorf("unexpected error removing subdomain %s: %w", name, err)
+		}
+	}
+	return plan, nil
+}
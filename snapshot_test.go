@@ -0,0 +1,151 @@
+package loopia
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider_SnapshotRestore(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	mx := zone.add("@", loopiaRecord{Type: "MX", RData: "mx1.loopia.se.", Priority: 10, TTL: 3600})
+	www := zone.add("www",
+		loopiaRecord{Type: "A", RData: "192.0.2.1", TTL: 300},
+		loopiaRecord{Type: "TXT", RData: "keep", TTL: 300},
+	)
+	zone.add("empty")
+	p := tc.getProvider()
+	store := SnapshotStore{Dir: t.TempDir()}
+
+	version, err := p.SnapshotZone(context.TODO(), "test.local.", store)
+	require.NoError(t, err)
+	versions, err := store.Versions("test.local")
+	require.NoError(t, err)
+	assert.Equal(t, []string{version}, versions)
+
+	snapshot, err := store.Latest("test.local")
+	require.NoError(t, err)
+	assert.Equal(t, "test.local", snapshot.Zone)
+	assert.Equal(t, []string{"@", "www", "empty"}, snapshot.Subdomains)
+	assert.Equal(t, []SnapshotRecord{
+		{ID: mx[0], Name: "@", Type: "MX", Data: "10 mx1.loopia.se.", TTL: 3600},
+		{ID: www[0], Name: "www", Type: "A", Data: "192.0.2.1", TTL: 300},
+		{ID: www[1], Name: "www", Type: "TXT", Data: "keep", TTL: 300},
+	}, snapshot.Records)
+
+	// the risky change
+	_, err = p.SetRecords(context.TODO(), "test.local", []libdns.Record{
+		libdns.RR{Name: "www", Type: "A", Data: "198.51.100.1", TTL: time.Hour},
+	})
+	require.NoError(t, err)
+	_, err = p.DeleteRecords(context.TODO(), "test.local", []libdns.Record{
+		libdns.RR{Name: "@", Type: "MX", Data: "10 mx1.loopia.se.", TTL: time.Hour},
+	})
+	require.NoError(t, err)
+	require.NoError(t, p.RemoveSubdomain(context.TODO(), "test.local", "empty"))
+	require.NoError(t, p.AddSubdomain(context.TODO(), "test.local", "extra"))
+
+	plan, err := p.Restore(context.TODO(), snapshot, PlanOptions{}, nil)
+	require.NoError(t, err)
+	assert.Len(t, plan.Changes, 2, "only the changed records are restored")
+	assert.Equal(t, loopiaRecord{ID: www[0], Type: "A", RData: "192.0.2.1", TTL: 300}, zone.get("www")[0])
+	assert.Equal(t, "mx1.loopia.se.", zone.get("@")[0].RData)
+	assert.True(t, zone.hasSubdomain("empty"))
+	assert.False(t, zone.hasSubdomain("extra"))
+
+	plan, err = p.PlanRestore(context.TODO(), snapshot, PlanOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, plan.Changes)
+}
+
+func TestProvider_SnapshotAccount(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	zone.add("www", loopiaRecord{Type: "A", RData: "192.0.2.1", TTL: 300})
+	tc.handle("getDomains", func(t *testing.T, w http.ResponseWriter, params []string) {
+		writeValue(w, "<array><data><value>"+fmt.Sprintf(domainValue, "example.org")+
+			"</value><value>"+fmt.Sprintf(domainValue, "example.com")+"</value></data></array>")
+	})
+	p := tc.getProvider()
+	store := SnapshotStore{Dir: t.TempDir()}
+
+	versions, err := p.SnapshotAccount(context.TODO(), store)
+	require.NoError(t, err)
+	assert.Len(t, versions, 2)
+	for _, zone := range []string{"example.org", "example.com"} {
+		assert.FileExists(t, filepath.Join(store.Dir, zone, versions[zone]+".json"))
+	}
+}
+
+func TestSnapshotStore(t *testing.T) {
+	store := SnapshotStore{Dir: t.TempDir()}
+	versions, err := store.Versions("test.local")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+	_, err = store.Latest("test.local")
+	assert.EqualError(t, err, "no snapshots of test.local")
+
+	created := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		_, err = store.Save(&Snapshot{Version: snapshotVersion, Zone: "test.local", Created: created.Add(time.Duration(i) * time.Second)})
+		require.NoError(t, err)
+	}
+	_, err = store.Save(&Snapshot{Version: snapshotVersion, Zone: "test.local.", Created: created, Subdomains: []string{"www"}})
+	assert.ErrorContains(t, err, "already exists")
+	kept, err := store.Load("test.local", "20261019T120000.000000000Z")
+	require.NoError(t, err)
+	assert.Empty(t, kept.Subdomains, "an existing snapshot should not be overwritten")
+
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			_, err := store.Save(&Snapshot{Version: snapshotVersion, Zone: "test.local", Created: created.Add(time.Minute)})
+			errs <- err
+		}()
+	}
+	saved := 0
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err == nil {
+			saved++
+		} else {
+			assert.ErrorContains(t, err, "already exists")
+		}
+	}
+	assert.Equal(t, 1, saved, "only one of concurrent saves of a version should succeed")
+	require.NoError(t, os.Remove(filepath.Join(store.Dir, "test.local", "20261019T120100.000000000Z.json")))
+
+	for _, zone := range []string{"../evil.org", "..", "a/../../evil.org", `..\evil.org`, ".hidden.org"} {
+		_, err = store.Save(&Snapshot{Version: snapshotVersion, Zone: zone, Created: created})
+		assert.ErrorContains(t, err, "invalide zone", zone)
+		_, err = store.Versions(zone)
+		assert.ErrorContains(t, err, "invalide zone", zone)
+	}
+	_, err = os.Stat(filepath.Join(filepath.Dir(store.Dir), "evil.org"))
+	assert.True(t, os.IsNotExist(err), "nothing should be written outside the store")
+
+	versions, err = store.Versions("test.local")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20261019T120000.000000000Z", "20261019T120001.000000000Z"}, versions)
+	latest, err := store.Latest("test.local")
+	require.NoError(t, err)
+	assert.Equal(t, created.Add(time.Second), latest.Created)
+
+	_, err = store.Load("test.local", "../other")
+	assert.EqualError(t, err, "invalid snapshot version '../other'")
+	require.NoError(t, os.WriteFile(filepath.Join(store.Dir, "test.local", "20261019T130000.000000000Z.json"), []byte(`{"version": 9}`), 0o644))
+	_, err = store.Latest("test.local")
+	assert.EqualError(t, err, "unsupported snapshot version 9")
+}
@@ -0,0 +1,116 @@
+package loopia
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ListSubdomains lists the subdomains in the zone, relative to it. For a zone
+// below the Loopia domain, like sub.example.org, only the subdomains below it
+// are listed and the zone itself is listed as "@".
+func (p *Provider) ListSubdomains(ctx context.Context, zone string) ([]string, error) {
+	ctx, span := p.startSpan(ctx, "ListSubdomains", zone, 0)
+	unlock := p.lock("ListSubdomains", true)
+	defer unlock()
+	ctx = addTrace(ctx, "ListSubdomains")
+	result, err := p.listSubdomains(ctx, zone)
+	finishSpan(span, len(result), err)
+	return result, err
+}
+
+// AddSubdomain adds the subdomain name, relative to the zone, without any
+// records.
+func (p *Provider) AddSubdomain(ctx context.Context, zone, name string) error {
+	ctx, span := p.startSpan(ctx, "AddSubdomain", zone, 0)
+	unlock := p.lock("AddSubdomain", false)
+	defer unlock()
+	ctx = addTrace(ctx, "AddSubdomain")
+	n, z := loopify(name, cleanZone(zone))
+	err := p.addSubdomain(ctx, z, n)
+	finishSpan(span, 0, err)
+	return err
+}
+
+// RemoveSubdomain removes the subdomain name, relative to the zone. Loopia
+// removes any records left in it as well.
+func (p *Provider) RemoveSubdomain(ctx context.Context, zone, name string) error {
+	ctx, span := p.startSpan(ctx, "RemoveSubdomain", zone, 0)
+	unlock := p.lock("RemoveSubdomain", false)
+	defer unlock()
+	ctx = addTrace(ctx, "RemoveSubdomain")
+	n, z := loopify(name, cleanZone(zone))
+	err := p.removeSubdomain(ctx, z, n)
+	finishSpan(span, 0, err)
+	return err
+}
+
+func (p *Provider) listSubdomains(ctx context.Context, zone string) ([]string, error) {
+	if !validZone(zone) {
+		return nil, fmt.Errorf("invalide zone '%s'", zone)
+	}
+	apex, domain := loopify("@", cleanZone(zone))
+	names, err := p.getSubdomains(ctx, domain)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error getting subdomains: %w", err)
+	}
+	if apex == "@" {
+		return names, nil
+	}
+	result := []string{}
+	for _, name := range names {
+		if name == apex {
+			result = append(result, "@")
+		} else if strings.HasSuffix(name, "."+apex) {
+			result = append(result, strings.TrimSuffix(name, "."+apex))
+		}
+	}
+	return result, nil
+}
+
+// addSubdomain adds a subdomain to a Loopia domain. The name is used as is,
+// loopify it first if needed.
+func (p *Provider) addSubdomain(ctx context.Context, zone, name string) error {
+	p.log().DebugContext(ctx, "addSubdomain", LogKeyZone, zone, LogKeyName, name)
+	if !validZone(zone) {
+		return fmt.Errorf("invalide zone '%s'", zone)
+	}
+	if name == "" {
+		return fmt.Errorf("invalid name '%s'", name)
+	}
+	zone = cleanZone(zone)
+	var response string
+	err := p.call(ctx, "addSubdomain", params(zone, name), &response)
+	p.state().cache.invalidateSubdomains(zone)
+	if err == nil && response != statusOK {
+		err = fmt.Errorf("unexpected error adding subdomain: %w", &StatusError{Method: "addSubdomain", Status: response})
+	} else if err != nil {
+		err = fmt.Errorf("unexpected error adding subdomain: %w", err)
+	}
+	p.audit(ctx, AuditEntry{Action: AuditAddSubdomain, Zone: zone, Name: name}, err)
+	return err
+}
+
+// removeSubdomain removes a subdomain, and its records, from a Loopia domain.
+// The name is used as is, loopify it first if needed.
+func (p *Provider) removeSubdomain(ctx context.Context, zone, name string) error {
+	p.log().DebugContext(ctx, "removeSubdomain", LogKeyZone, zone, LogKeyName, name)
+	if !validZone(zone) {
+		return fmt.Errorf("invalide zone '%s'", zone)
+	}
+	if name == "" {
+		return fmt.Errorf("invalid name '%s'", name)
+	}
+	zone = cleanZone(zone)
+	var response string
+	err := p.call(ctx, "removeSubdomain", params(zone, name), &response)
+	p.state().cache.invalidateSubdomains(zone)
+	p.state().cache.invalidateRecords(zone, name)
+	if err == nil && response != statusOK {
+		err = fmt.Errorf("unexpected error removing subdomain: %w", &StatusError{Method: "removeSubdomain", Status: response})
+	} else if err != nil {
+		err = fmt.Errorf("unexpected error removing subdomain: %w", err)
+	}
+	p.audit(ctx, AuditEntry{Action: AuditRemoveSubdomain, Zone: zone, Name: name}, err)
+	return err
+}
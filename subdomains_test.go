@@ -0,0 +1,59 @@
+package loopia
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider_ListSubdomains(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	for _, name := range []string{"@", "www", "sub", "www.sub", "a.b.sub", "notsub"} {
+		zone.add(name)
+	}
+	p := tc.getProvider()
+
+	names, err := p.ListSubdomains(context.TODO(), "test.local.")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"@", "www", "sub", "www.sub", "a.b.sub", "notsub"}, names)
+
+	names, err = p.ListSubdomains(context.TODO(), "sub.test.local.")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"@", "www", "a.b"}, names)
+}
+
+func TestProvider_AddRemoveSubdomain(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	sink := &memoryAudit{}
+	p := tc.getProvider()
+	p.SetAuditSink(sink)
+
+	assert.NoError(t, p.AddSubdomain(context.TODO(), "sub.test.local", "www"))
+	assert.True(t, zone.hasSubdomain("www.sub"))
+	assert.NoError(t, p.AddSubdomain(context.TODO(), "sub.test.local", "@"))
+	assert.True(t, zone.hasSubdomain("sub"))
+
+	assert.NoError(t, p.RemoveSubdomain(context.TODO(), "sub.test.local", "www"))
+	assert.False(t, zone.hasSubdomain("www.sub"))
+	assert.True(t, zone.hasSubdomain("sub"))
+
+	tc.handle("removeSubdomain", func(t *testing.T, w http.ResponseWriter, params []string) {
+		writeValue(w, stringValue("AUTH_ERROR"))
+	})
+	err := p.RemoveSubdomain(context.TODO(), "test.local", "sub")
+	assert.EqualError(t, err, "unexpected error removing subdomain: AUTH_ERROR")
+
+	assert.Len(t, sink.entries, 4)
+	assert.Equal(t, AuditAddSubdomain, sink.entries[0].Action)
+	assert.Equal(t, "www.sub", sink.entries[0].Name)
+	assert.Equal(t, AuditRemoveSubdomain, sink.entries[3].Action)
+	assert.Equal(t, AuditFailure, sink.entries[3].Outcome)
+}
@@ -0,0 +1,429 @@
+package loopia
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// planVersion is the version of the plan format written by Plan.Write.
+const planVersion = 1
+
+// ChangeAction is what a Change does to a record.
+type ChangeAction string
+
+// Actions of a Change, in the order Apply runs them. Deletes run before
+// creates so a name can change type, like from A to CNAME, which can not be
+// next to other records.
+const (
+	ChangeUpdate ChangeAction = "update"
+	ChangeDelete ChangeAction = "delete"
+	ChangeCreate ChangeAction = "create"
+)
+
+var changeOrder = map[ChangeAction]int{
+	ChangeUpdate: 0,
+	ChangeDelete: 1,
+	ChangeCreate: 2,
+}
+
+// PlanRecord is the value of a record in a Change, as libdns has it.
+type PlanRecord struct {
+	Data string `json:"data"`
+	// TTL in seconds.
+	TTL int `json:"ttl"`
+}
+
+// Change is one step of a Plan.
+type Change struct {
+	Action ChangeAction `json:"action"`
+	// Name is relative to the zone of the plan.
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// ID is the Loopia ID of the record updated or deleted.
+	ID     int64       `json:"id,omitempty"`
+	Before *PlanRecord `json:"before,omitempty"`
+	After  *PlanRecord `json:"after,omitempty"`
+}
+
+// String describes the change on one line.
+func (c Change) String() string {
+	switch c.Action {
+	case ChangeCreate:
+		return fmt.Sprintf("create %s %d %s %s", c.Name, c.After.TTL, c.Type, c.After.Data)
+	case ChangeUpdate:
+		return fmt.Sprintf("update %s %s #%d: %d %s -> %d %s", c.Name, c.Type, c.ID,
+			c.Before.TTL, c.Before.Data, c.After.TTL, c.After.Data)
+	}
+	return fmt.Sprintf("delete %s %d %s %s #%d", c.Name, c.Before.TTL, c.Type, c.Before.Data, c.ID)
+}
+
+func (c Change) record(r *PlanRecord) (libdns.Record, error) {
+	return libdns.RR{
+		Name: c.Name,
+		Type: c.Type,
+		Data: r.Data,
+		TTL:  time.Duration(r.TTL) * time.Second,
+	}.Parse()
+}
+
+// Plan is the changes that make a zone match a desired set of records. It
+// can be written to a file, reviewed and applied later with Apply.
+type Plan struct {
+	Version int       `json:"version"`
+	Zone    string    `json:"zone"`
+	Created time.Time `json:"created"`
+	Changes []Change  `json:"changes"`
+}
+
+// Write writes the plan to w as JSON.
+func (p *Plan) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// ReadPlan reads a plan written by Plan.Write.
+func ReadPlan(r io.Reader) (*Plan, error) {
+	plan := &Plan{}
+	if err := json.NewDecoder(r).Decode(plan); err != nil {
+		return nil, err
+	}
+	if plan.Version != planVersion {
+		return nil, fmt.Errorf("unsupported plan version %d", plan.Version)
+	}
+	return plan, nil
+}
+
+// PlanOptions controls what Plan manages.
+type PlanOptions struct {
+	// Ignore are names, relative to the zone, that are left as they are.
+	// They may use the patterns of path.Match, like _acme-challenge.*.
+	Ignore []string
+	// IgnoreTypes are record types that are left as they are, like NS.
+	IgnoreTypes []string
+}
+
+func (o PlanOptions) ignored(name, rrType string) bool {
+	for _, t := range o.IgnoreTypes {
+		if strings.EqualFold(t, rrType) {
+			return true
+		}
+	}
+	for _, pattern := range o.Ignore {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyProgress is called by Apply after each step, err is the outcome of it.
+type ApplyProgress func(step, total int, change Change, err error)
+
+// Plan compares the zone with desired, the complete set of records it should
+// have, and returns the changes needed. Nothing is changed.
+func (p *Provider) Plan(ctx context.Context, zone string, desired []libdns.Record, opts PlanOptions) (*Plan, error) {
+	ctx, span := p.startSpan(ctx, "Plan", zone, len(desired))
+	unlock := p.lock("Plan", true)
+	defer unlock()
+	ctx = addTrace(ctx, "Plan")
+	plan, err := p.plan(ctx, zone, desired, opts)
+	changes := 0
+	if plan != nil {
+		changes = len(plan.Changes)
+	}
+	finishSpan(span, changes, err)
+	return plan, err
+}
+
+// Apply runs the changes of plan in order, calling progress, if not nil,
+// after each. It checks that the records to update or delete are unchanged
+// since the plan was made before it changes anything, and stops at the first
+// failure.
+func (p *Provider) Apply(ctx context.Context, plan *Plan, progress ApplyProgress) error {
+	ctx, span := p.startSpan(ctx, "Apply", plan.Zone, len(plan.Changes))
+	unlock := p.lock("Apply", false)
+	defer unlock()
+	ctx = addTrace(ctx, "Apply")
+	err := p.apply(ctx, plan, progress)
+	finishSpan(span, len(plan.Changes), err)
+	return err
+}
+
+// planEntry is a record in the zone with its ID.
+type planEntry struct {
+	id     int64
+	record libdns.RR
+}
+
+func (p *Provider) plan(ctx context.Context, zone string, desired []libdns.Record, opts PlanOptions) (*Plan, error) {
+	p.log().DebugContext(ctx, "plan", LogKeyZone, zone, "records", len(desired))
+	if !validZone(zone) {
+		return nil, fmt.Errorf("invalide zone '%s'", zone)
+	}
+	zone = cleanZone(zone)
+	for i, r := range desired {
+		if !validRecord(r) {
+			return nil, fmt.Errorf("record %d is invalid", i)
+		}
+	}
+	_, current, err := p.zoneEntries(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	return &Plan{
+		Version: planVersion,
+		Zone:    zone,
+		Created: time.Now().UTC(),
+		Changes: diffRecords(current, desired, opts),
+	}, nil
+}
+
+// zoneEntries returns the subdomains of a zone and all records in them.
+func (p *Provider) zoneEntries(ctx context.Context, zone string) ([]string, []planEntry, error) {
+	names, err := p.listSubdomains(ctx, zone)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries := []planEntry{}
+	for _, name := range names {
+		n, z := loopify(name, zone)
+		records := []loopiaRecord{}
+		if err := p.getLoopiaRecords(ctx, z, n, &records); err != nil {
+			return nil, nil, fmt.Errorf("unexpected error getting zone records: %w", err)
+		}
+		for _, r := range records {
+			rr, err := r.libdnsRecord(name)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unexpected error converting record: %w", err)
+			}
+			entries = append(entries, planEntry{r.ID, rr.RR()})
+		}
+	}
+	return names, entries, nil
+}
+
+// rrsetKey identifies the records of one name and type.
+type rrsetKey struct {
+	name   string
+	rrType string
+}
+
+// diffRecords returns the changes that turn current into desired, RRset by
+// RRset. Records with the same data are kept, or updated if only the TTL
+// differs. Other records in an RRset are updated in place before records are
+// created or deleted.
+func diffRecords(current []planEntry, desired []libdns.Record, opts PlanOptions) []Change {
+	keys := []rrsetKey{}
+	cur := make(map[rrsetKey][]planEntry)
+	des := make(map[rrsetKey][]libdns.RR)
+	add := func(k rrsetKey) {
+		if _, ok := cur[k]; ok {
+			return
+		}
+		if _, ok := des[k]; ok {
+			return
+		}
+		keys = append(keys, k)
+	}
+	for _, e := range current {
+		k := rrsetKey{planName(e.record.Name), strings.ToUpper(e.record.Type)}
+		if opts.ignored(k.name, k.rrType) {
+			continue
+		}
+		add(k)
+		cur[k] = append(cur[k], e)
+	}
+	for _, r := range desired {
+		rr := r.RR()
+		k := rrsetKey{planName(rr.Name), strings.ToUpper(rr.Type)}
+		if opts.ignored(k.name, k.rrType) {
+			continue
+		}
+		add(k)
+		des[k] = append(des[k], rr)
+	}
+
+	changes := []Change{}
+	for _, k := range keys {
+		used := make(map[int]bool)
+		pending := []libdns.RR{}
+	DESIRED:
+		for _, rr := range des[k] {
+			for i, e := range cur[k] {
+				if used[i] || e.record.Data != rr.Data {
+					continue
+				}
+				used[i] = true
+				if e.record.TTL != rr.TTL {
+					changes = append(changes, updateChange(k, e, rr))
+				}
+				continue DESIRED
+			}
+			pending = append(pending, rr)
+		}
+	PENDING:
+		for _, rr := range pending {
+			for i, e := range cur[k] {
+				if used[i] {
+					continue
+				}
+				used[i] = true
+				changes = append(changes, updateChange(k, e, rr))
+				continue PENDING
+			}
+			changes = append(changes, Change{Action: ChangeCreate, Name: k.name, Type: k.rrType, After: planRecord(rr)})
+		}
+		for i, e := range cur[k] {
+			if !used[i] {
+				changes = append(changes, Change{Action: ChangeDelete, Name: k.name, Type: k.rrType, ID: e.id, Before: planRecord(e.record)})
+			}
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Action != b.Action {
+			return changeOrder[a.Action] < changeOrder[b.Action]
+		}
+		if a.Name != b.Name {
+			return nameLess(a.Name, b.Name)
+		}
+		return a.Type < b.Type
+	})
+	return changes
+}
+
+func updateChange(k rrsetKey, e planEntry, rr libdns.RR) Change {
+	return Change{
+		Action: ChangeUpdate,
+		Name:   k.name,
+		Type:   k.rrType,
+		ID:     e.id,
+		Before: planRecord(e.record),
+		After:  planRecord(rr),
+	}
+}
+
+func planRecord(rr libdns.RR) *PlanRecord {
+	return &PlanRecord{Data: rr.Data, TTL: int(rr.TTL / time.Second)}
+}
+
+func planName(name string) string {
+	if name == "" {
+		return "@"
+	}
+	return name
+}
+
+func (p *Provider) apply(ctx context.Context, plan *Plan, progress ApplyProgress) error {
+	p.log().DebugContext(ctx, "apply", LogKeyZone, plan.Zone, "changes", len(plan.Changes))
+	if !validZone(plan.Zone) {
+		return fmt.Errorf("invalide zone '%s'", plan.Zone)
+	}
+	zone := cleanZone(plan.Zone)
+	if err := p.checkPlan(ctx, zone, plan); err != nil {
+		return err
+	}
+
+	deleted := []cacheKey{}
+	seen := make(map[cacheKey]bool)
+	for i, c := range plan.Changes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, z := loopify(c.Name, zone)
+		key := cacheKey{z, n}
+		var err error
+		switch c.Action {
+		case ChangeCreate:
+			var record libdns.Record
+			if record, err = c.record(c.After); err == nil {
+				_, err = p.addDNSEntries(ctx, zone, []libdns.Record{record})
+			}
+		case ChangeUpdate:
+			var before, after libdns.Record
+			var lr loopiaRecord
+			if before, err = c.record(c.Before); err != nil {
+				break
+			}
+			if lr, err = toLoopiaRecord(before, c.ID); err != nil {
+				break
+			}
+			if after, err = c.record(c.After); err == nil {
+				_, err = p.updateZoneRecord(ctx, zone, after, lr)
+			}
+		case ChangeDelete:
+			var before libdns.Record
+			var lr loopiaRecord
+			if before, err = c.record(c.Before); err != nil {
+				break
+			}
+			if lr, err = toLoopiaRecord(before, c.ID); err == nil {
+				err = p.removeDNSEntry(ctx, key.domain, key.name, lr)
+			}
+			if err == nil && !seen[key] {
+				seen[key] = true
+				deleted = append(deleted, key)
+			}
+		default:
+			err = fmt.Errorf("unknown action '%s'", c.Action)
+		}
+		p.log().InfoContext(ctx, "applied change", "change", c.String(), "error", err)
+		if progress != nil {
+			progress(i+1, len(plan.Changes), c, err)
+		}
+		if err != nil {
+			return fmt.Errorf("unexpected error applying change %d, %s: %w", i+1, c, err)
+		}
+	}
+	for _, key := range deleted {
+		p.removeEmptySubdomain(ctx, key.domain, key.name)
+	}
+	return nil
+}
+
+// checkPlan fails if any record the plan updates or deletes is no longer as
+// it was when the plan was made. The records are read past the cache.
+func (p *Provider) checkPlan(ctx context.Context, zone string, plan *Plan) error {
+	byName := make(map[cacheKey]map[int64]libdns.RR)
+	for _, c := range plan.Changes {
+		if c.Action == ChangeCreate {
+			continue
+		}
+		if c.Before == nil || c.ID == 0 {
+			return fmt.Errorf("invalid change: %s %s without the record it changes", c.Action, c.Name)
+		}
+		n, z := loopify(c.Name, zone)
+		key := cacheKey{z, n}
+		records, ok := byName[key]
+		if !ok {
+			p.state().cache.invalidateRecords(cleanZone(z), n)
+			found := []loopiaRecord{}
+			if err := p.getLoopiaRecords(ctx, z, n, &found); err != nil {
+				return fmt.Errorf("unexpected error getting zone records: %w", err)
+			}
+			records = make(map[int64]libdns.RR)
+			for _, r := range found {
+				rr, err := r.libdnsRecord(c.Name)
+				if err != nil {
+					return fmt.Errorf("unexpected error converting record: %w", err)
+				}
+				records[r.ID] = rr.RR()
+			}
+			byName[key] = records
+		}
+		rr, ok := records[c.ID]
+		if !ok || rr.Data != c.Before.Data || int(rr.TTL/time.Second) != c.Before.TTL {
+			return fmt.Errorf("plan is stale, record %d of %s has changed", c.ID, c.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package loopia
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider_PlanApply(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	apex := zone.add("@",
+		loopiaRecord{Type: "A", RData: "192.0.2.1", TTL: 300},
+		loopiaRecord{Type: "NS", RData: "ns1.loopia.se.", TTL: 3600},
+	)
+	www := zone.add("www",
+		loopiaRecord{Type: "A", RData: "192.0.2.2", TTL: 300},
+		loopiaRecord{Type: "A", RData: "192.0.2.3", TTL: 300},
+	)
+	old := zone.add("old", loopiaRecord{Type: "TXT", RData: "gone", TTL: 300})
+	zone.add("_acme-challenge.www", loopiaRecord{Type: "TXT", RData: "token", TTL: 300})
+	p := tc.getProvider()
+
+	plan, err := p.Plan(context.TODO(), "test.local", []libdns.Record{
+		libdns.RR{Name: "@", Type: "A", Data: "192.0.2.1", TTL: time.Hour},
+		libdns.RR{Name: "www", Type: "A", Data: "192.0.2.2", TTL: 5 * time.Minute},
+		libdns.RR{Name: "www", Type: "A", Data: "192.0.2.4", TTL: 5 * time.Minute},
+		libdns.RR{Name: "new", Type: "A", Data: "192.0.2.5", TTL: 5 * time.Minute},
+	}, PlanOptions{Ignore: []string{"_acme-challenge.*"}, IgnoreTypes: []string{"ns"}})
+	require.NoError(t, err)
+	assert.Equal(t, "test.local", plan.Zone)
+	assert.Equal(t, []Change{
+		{Action: ChangeUpdate, Name: "@", Type: "A", ID: apex[0], Before: &PlanRecord{"192.0.2.1", 300}, After: &PlanRecord{"192.0.2.1", 3600}},
+		{Action: ChangeUpdate, Name: "www", Type: "A", ID: www[1], Before: &PlanRecord{"192.0.2.3", 300}, After: &PlanRecord{"192.0.2.4", 300}},
+		{Action: ChangeDelete, Name: "old", Type: "TXT", ID: old[0], Before: &PlanRecord{"gone", 300}},
+		{Action: ChangeCreate, Name: "new", Type: "A", After: &PlanRecord{"192.0.2.5", 300}},
+	}, plan.Changes)
+	assert.Equal(t, 0, tc.callCount("updateZoneRecord"), "planning changes nothing")
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, plan.Write(buf))
+	read, err := ReadPlan(buf)
+	require.NoError(t, err)
+	assert.Equal(t, plan.Changes, read.Changes)
+
+	steps := []int{}
+	err = p.Apply(context.TODO(), read, func(step, total int, c Change, err error) {
+		assert.NoError(t, err)
+		assert.Equal(t, 4, total)
+		steps = append(steps, step)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4}, steps)
+	assert.Equal(t, loopiaRecord{ID: apex[0], Type: "A", RData: "192.0.2.1", TTL: 3600}, zone.get("@")[0])
+	assert.Equal(t, loopiaRecord{ID: www[1], Type: "A", RData: "192.0.2.4", TTL: 300}, zone.get("www")[1])
+	assert.Len(t, zone.get("new"), 1)
+	assert.False(t, zone.hasSubdomain("old"), "emptied subdomains are removed")
+	assert.Len(t, zone.get("_acme-challenge.www"), 1)
+	assert.Len(t, zone.get("@"), 2)
+
+	plan, err = p.Plan(context.TODO(), "test.local", []libdns.Record{
+		libdns.RR{Name: "@", Type: "A", Data: "192.0.2.1", TTL: time.Hour},
+		libdns.RR{Name: "www", Type: "A", Data: "192.0.2.2", TTL: 5 * time.Minute},
+		libdns.RR{Name: "www", Type: "A", Data: "192.0.2.4", TTL: 5 * time.Minute},
+		libdns.RR{Name: "new", Type: "A", Data: "192.0.2.5", TTL: 5 * time.Minute},
+	}, PlanOptions{Ignore: []string{"_acme-challenge.*"}, IgnoreTypes: []string{"NS"}})
+	require.NoError(t, err)
+	assert.Empty(t, plan.Changes, "an applied plan leaves nothing to change")
+}
+
+func TestProvider_ApplyTypeChange(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	zone.add("www",
+		loopiaRecord{Type: "A", RData: "192.0.2.1", TTL: 300},
+		loopiaRecord{Type: "AAAA", RData: "2001:db8::1", TTL: 300},
+	)
+	// like Loopia, refuse a CNAME next to other records
+	add := tc.handler("addZoneRecord")
+	tc.handle("addZoneRecord", func(t *testing.T, w http.ResponseWriter, params []string) {
+		others := zone.get(params[3])
+		if r := recordParam(params); (r.Type == "CNAME" && len(others) > 0) || hasCNAME(others) {
+			writeValue(w, stringValue("BAD_INDATA"))
+			return
+		}
+		add(t, w, params)
+	})
+	p := tc.getProvider()
+
+	plan, err := p.Plan(context.TODO(), "test.local", []libdns.Record{
+		libdns.CNAME{Name: "www", Target: "example.org.", TTL: 5 * time.Minute},
+	}, PlanOptions{})
+	require.NoError(t, err)
+	actions := []ChangeAction{}
+	for _, c := range plan.Changes {
+		actions = append(actions, c.Action)
+	}
+	assert.Equal(t, []ChangeAction{ChangeDelete, ChangeDelete, ChangeCreate}, actions)
+
+	require.NoError(t, p.Apply(context.TODO(), plan, nil))
+	assert.Equal(t, []loopiaRecord{{ID: 1003, Type: "CNAME", RData: "example.org.", TTL: 300}}, zone.get("www"))
+	assert.True(t, zone.hasSubdomain("www"))
+}
+
+func hasCNAME(records []loopiaRecord) bool {
+	for _, r := range records {
+		if r.Type == "CNAME" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestProvider_ApplyStale(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	zone.add("www", loopiaRecord{Type: "A", RData: "192.0.2.1", TTL: 300})
+	p := tc.getProvider()
+
+	plan, err := p.Plan(context.TODO(), "test.local", []libdns.Record{
+		libdns.RR{Name: "www", Type: "A", Data: "192.0.2.2", TTL: 5 * time.Minute},
+		libdns.RR{Name: "new", Type: "A", Data: "192.0.2.3", TTL: 5 * time.Minute},
+	}, PlanOptions{})
+	require.NoError(t, err)
+	require.Len(t, plan.Changes, 2)
+
+	_, err = p.SetRecords(context.TODO(), "test.local", []libdns.Record{
+		libdns.RR{Name: "www", Type: "A", Data: "192.0.2.9", TTL: 5 * time.Minute},
+	})
+	require.NoError(t, err)
+
+	err = p.Apply(context.TODO(), plan, nil)
+	assert.ErrorContains(t, err, "plan is stale")
+	assert.False(t, zone.hasSubdomain("new"), "nothing is applied from a stale plan")
+}
+
+func TestReadPlan(t *testing.T) {
+	_, err := ReadPlan(bytes.NewBufferString(`{"version": 2, "zone": "test.local"}`))
+	assert.ErrorContains(t, err, "unsupported plan version 2")
+}
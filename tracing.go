@@ -0,0 +1,109 @@
+package loopia
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/libdns/loopia"
+
+// Span attribute keys.
+const (
+	attrMethod     = attribute.Key("loopia.method")
+	attrZone       = attribute.Key("loopia.zone")
+	attrSubdomain  = attribute.Key("loopia.subdomain")
+	attrRecordsIn  = attribute.Key("loopia.records.in")
+	attrRecordsOut = attribute.Key("loopia.records.out")
+	attrStatus     = attribute.Key("loopia.status")
+)
+
+type tracerHolder struct {
+	tracer trace.Tracer
+}
+
+// SetTracerProvider sets the OpenTelemetry tracer provider used for the spans
+// of this provider. Without it, or with nil, the global provider is used.
+func (p *Provider) SetTracerProvider(tp trace.TracerProvider) {
+	if tp == nil {
+		p.state().tracer.Store(nil)
+		return
+	}
+	p.state().tracer.Store(&tracerHolder{tp.Tracer(tracerName)})
+}
+
+func (p *Provider) getTracer() trace.Tracer {
+	if h := p.state().tracer.Load(); h != nil {
+		return h.tracer
+	}
+	return otel.GetTracerProvider().Tracer(tracerName)
+}
+
+// startSpan starts the span of a public method, records is the number of
+// records given to it.
+func (p *Provider) startSpan(ctx context.Context, method, zone string, records int) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return p.getTracer().Start(ctx, "loopia."+method, trace.WithAttributes(
+		attrMethod.String(method),
+		attrZone.String(zone),
+		attrRecordsIn.Int(records),
+	))
+}
+
+// finishSpan ends a span started by startSpan, records is the number of
+// records returned.
+func finishSpan(span trace.Span, records int, err error) {
+	span.SetAttributes(attrRecordsOut.Int(records))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startRPCSpan starts the span of a single call to the Loopia API.
+func (p *Provider) startRPCSpan(ctx context.Context, method string, args []interface{}) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	attrs := []attribute.KeyValue{attrMethod.String(method)}
+	if zone, ok := argString(args, 0); ok {
+		attrs = append(attrs, attrZone.String(zone))
+	}
+	if name, ok := argString(args, 1); ok {
+		attrs = append(attrs, attrSubdomain.String(name))
+	}
+	return p.getTracer().Start(ctx, "loopia.rpc "+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// finishRPCSpan ends a span started by startRPCSpan.
+func finishRPCSpan(span trace.Span, reply interface{}, err error) {
+	status := rpcStatus(reply, err)
+	span.SetAttributes(attrStatus.String(status))
+	if records, ok := reply.(*[]loopiaRecord); ok && err == nil {
+		span.SetAttributes(attrRecordsOut.Int(len(*records)))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else if status != statusOK {
+		span.SetStatus(codes.Error, status)
+	}
+	span.End()
+}
+
+func argString(args []interface{}, i int) (string, bool) {
+	if i >= len(args) {
+		return "", false
+	}
+	s, ok := args[i].(string)
+	return s, ok
+}
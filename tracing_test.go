@@ -0,0 +1,76 @@
+package loopia
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/libdns/libdns"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func spanAttrs(s tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, a := range s.Attributes {
+		attrs[a.Key] = a.Value
+	}
+	return attrs
+}
+
+func TestProvider_SetTracerProvider(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	p := tc.getProvider()
+	p.SetTracerProvider(tp)
+
+	parentCtx, parent := tp.Tracer("caddy").Start(context.TODO(), "caddy")
+	_, err := p.GetRecordsByName(parentCtx, "test.local", "_test")
+	assert.NoError(t, err)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 3)
+	rpc, method := spans[0], spans[1]
+
+	assert.Equal(t, "loopia.rpc getZoneRecords", rpc.Name)
+	assert.Equal(t, method.SpanContext.SpanID(), rpc.Parent.SpanID())
+	attrs := spanAttrs(rpc)
+	assert.Equal(t, "getZoneRecords", attrs[attrMethod].AsString())
+	assert.Equal(t, "test.local", attrs[attrZone].AsString())
+	assert.Equal(t, "_test", attrs[attrSubdomain].AsString())
+	assert.Equal(t, int64(1), attrs[attrRecordsOut].AsInt64())
+	assert.Equal(t, "OK", attrs[attrStatus].AsString())
+
+	assert.Equal(t, "loopia.GetRecordsByName", method.Name)
+	assert.Equal(t, parent.SpanContext().SpanID(), method.Parent.SpanID(), "spans should join the trace in the context")
+	attrs = spanAttrs(method)
+	assert.Equal(t, "test.local", attrs[attrZone].AsString())
+	assert.Equal(t, int64(0), attrs[attrRecordsIn].AsInt64())
+	assert.Equal(t, int64(1), attrs[attrRecordsOut].AsInt64())
+
+	exporter.Reset()
+	tc.handle("removeZoneRecord", func(t *testing.T, w http.ResponseWriter, params []string) {
+		writeValue(w, stringValue("AUTH_ERROR"))
+	})
+	_, err = p.DeleteRecords(context.TODO(), "test.local", []libdns.Record{libdns.TXT{Name: "_test"}})
+	assert.Error(t, err)
+	spans = exporter.GetSpans()
+	for _, s := range spans {
+		if s.Name == "loopia.rpc removeZoneRecord" {
+			assert.Equal(t, "AUTH_ERROR", spanAttrs(s)[attrStatus].AsString())
+			assert.Equal(t, codes.Error, s.Status.Code)
+		}
+	}
+	last := spans[len(spans)-1]
+	assert.Equal(t, "loopia.DeleteRecords", last.Name)
+	assert.Equal(t, codes.Error, last.Status.Code)
+	attrs = spanAttrs(last)
+	assert.Equal(t, int64(1), attrs[attrRecordsIn].AsInt64(), "the input count should be kept")
+	assert.Equal(t, int64(0), attrs[attrRecordsOut].AsInt64())
+}
@@ -0,0 +1,62 @@
+package loopia
+
+import (
+	"fmt"
+	"time"
+)
+
+// valueString reads a string from a value decoded into an interface.
+func valueString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// valueInt reads an int from a value decoded into an interface.
+func valueInt(v interface{}) int64 {
+	i, _ := v.(int64)
+	return i
+}
+
+// valueBool reads a bool from a value decoded into an interface. Loopia
+// replies with either booleans or 0 and 1.
+func valueBool(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case int64:
+		return b != 0
+	}
+	return false
+}
+
+// valueDate reads a date, like 2030-01-31, from a value decoded into an
+// interface. An empty date is the zero time.
+func valueDate(v interface{}) (time.Time, error) {
+	switch d := v.(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		return d, nil
+	case string:
+		if d == "" {
+			return time.Time{}, nil
+		}
+		return time.Parse(time.DateOnly, d)
+	}
+	return time.Time{}, fmt.Errorf("not a date: %v", v)
+}
+
+// valueMoney reads an amount from a value decoded into an interface.
+func valueMoney(v interface{}) (Money, error) {
+	switch m := v.(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return Money(m * 100), nil
+	case float64:
+		return moneyFromFloat(m)
+	case string:
+		return ParseMoney(m)
+	}
+	return 0, fmt.Errorf("not an amount: %v", v)
+}
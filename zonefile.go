@@ -0,0 +1,178 @@
+package loopia
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/libdns/libdns"
+)
+
+// txtChunk is the longest character-string allowed in a TXT record.
+const txtChunk = 255
+
+// ExportZone writes all records of the zone to w as a zone file, see
+// WriteZoneFile. The zone may be below a Loopia domain, like sub.example.org.
+func (p *Provider) ExportZone(ctx context.Context, zone string, w io.Writer) error {
+	ctx, span := p.startSpan(ctx, "ExportZone", zone, 0)
+	unlock := p.lock("ExportZone", true)
+	defer unlock()
+	ctx = addTrace(ctx, "ExportZone")
+	records, err := p.exportRecords(ctx, zone)
+	if err == nil {
+		err = WriteZoneFile(w, zone, records)
+	}
+	finishSpan(span, len(records), err)
+	return err
+}
+
+func (p *Provider) exportRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
+	if !validZone(zone) {
+		return nil, fmt.Errorf("invalide zone '%s'", zone)
+	}
+	_, entries, err := p.zoneEntries(ctx, cleanZone(zone))
+	if err != nil {
+		return nil, err
+	}
+	records := make([]libdns.Record, 0, len(entries))
+	for _, e := range entries {
+		records = append(records, e.record)
+	}
+	return records, nil
+}
+
+// WriteZoneFile writes records, with names relative to zone, as an RFC 1035
+// master file. The most common TTL becomes $TTL and records are sorted by
+// name, type and data so the same records always give the same file. Targets
+// of CNAME, MX, NS and SRV records are written as fully qualified names.
+func WriteZoneFile(w io.Writer, zone string, records []libdns.Record) error {
+	rrs := make([]libdns.RR, 0, len(records))
+	for _, r := range records {
+		rrs = append(rrs, r.RR())
+	}
+	sort.SliceStable(rrs, func(i, j int) bool {
+		a, b := rrs[i], rrs[j]
+		if a.Name != b.Name {
+			return nameLess(a.Name, b.Name)
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Data != b.Data {
+			return a.Data < b.Data
+		}
+		return a.TTL < b.TTL
+	})
+	ttl := defaultTTL(rrs)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "$ORIGIN %s.\n", cleanZone(zone))
+	fmt.Fprintf(bw, "$TTL %d\n", int(ttl/time.Second))
+	for _, rr := range rrs {
+		name := rr.Name
+		if name == "" {
+			name = "@"
+		}
+		recordTTL := ""
+		if rr.TTL != ttl {
+			recordTTL = fmt.Sprint(int(rr.TTL / time.Second))
+		}
+		fmt.Fprintf(bw, "%s\t%s\tIN\t%s\t%s\n", name, recordTTL, rr.Type, zoneFileData(rr))
+	}
+	return bw.Flush()
+}
+
+// nameLess sorts the apex first, then names by their labels from the right,
+// so subdomains follow their parent.
+func nameLess(a, b string) bool {
+	if a == "@" || b == "@" {
+		return a == "@" && b != "@"
+	}
+	la, lb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 1; i <= len(la) && i <= len(lb); i++ {
+		if x, y := la[len(la)-i], lb[len(lb)-i]; x != y {
+			return x < y
+		}
+	}
+	return len(la) < len(lb)
+}
+
+// defaultTTL returns the most common TTL of rrs, the lowest one on ties.
+func defaultTTL(rrs []libdns.RR) time.Duration {
+	counts := make(map[time.Duration]int)
+	var best time.Duration
+	for _, rr := range rrs {
+		counts[rr.TTL]++
+		c, b := counts[rr.TTL], counts[best]
+		if c > b || c == b && rr.TTL < best {
+			best = rr.TTL
+		}
+	}
+	return best
+}
+
+// zoneFileData returns the data of rr as written in a zone file.
+func zoneFileData(rr libdns.RR) string {
+	fields := strings.Fields(rr.Data)
+	switch rr.Type {
+	case "TXT":
+		return quoteTXT(rr.Data)
+	case "CNAME", "NS":
+		if len(fields) == 1 {
+			return fqdn(fields[0])
+		}
+	case "MX":
+		if len(fields) == 2 {
+			return fields[0] + " " + fqdn(fields[1])
+		}
+	case "SRV":
+		if len(fields) == 4 {
+			return strings.Join(fields[:3], " ") + " " + fqdn(fields[3])
+		}
+	}
+	return rr.Data
+}
+
+// fqdn adds the trailing dot to a target name, unless it is the origin.
+func fqdn(name string) string {
+	if name == "@" || name == "." || strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// quoteTXT quotes text as one or more character-strings of at most 255 bytes.
+// Strings are only split between UTF-8 characters. Quotes and backslashes are
+// escaped with a backslash, control characters and bytes that are not valid
+// UTF-8 as \DDD, like RFC 1035 section 5.1 describes.
+func quoteTXT(text string) string {
+	chunks := []string{}
+	chunk := strings.Builder{}
+	size := 0
+	for len(text) > 0 {
+		r, n := utf8.DecodeRuneInString(text)
+		if size+n > txtChunk {
+			chunks = append(chunks, `"`+chunk.String()+`"`)
+			chunk.Reset()
+			size = 0
+		}
+		switch {
+		case r == utf8.RuneError && n == 1, r < 0x20, r == 0x7f:
+			fmt.Fprintf(&chunk, `\%03d`, text[0])
+		case r == '"' || r == '\\':
+			chunk.WriteByte('\\')
+			chunk.WriteRune(r)
+		default:
+			chunk.WriteString(text[:n])
+		}
+		size += n
+		text = text[n:]
+	}
+	chunks = append(chunks, `"`+chunk.String()+`"`)
+	return strings.Join(chunks, " ")
+}
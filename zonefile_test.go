@@ -0,0 +1,108 @@
+package loopia
+
+import (
+	"bytes"
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/libdns/libdns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteZoneFile(t *testing.T) {
+	records := []libdns.Record{
+		libdns.TXT{Name: "@", Text: `v=spf1 include:"loopia" ~all`, TTL: time.Hour},
+		libdns.Address{Name: "www.sub", IP: netip.MustParseAddr("192.0.2.2"), TTL: time.Hour},
+		libdns.CNAME{Name: "www", Target: "example.org", TTL: 300 * time.Second},
+		libdns.MX{Name: "@", Preference: 20, Target: "mx2.loopia.se", TTL: time.Hour},
+		libdns.MX{Name: "@", Preference: 10, Target: "mx1.loopia.se.", TTL: time.Hour},
+		libdns.SRV{Service: "sip", Transport: "tcp", Name: "@", Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.org", TTL: time.Hour},
+		libdns.Address{Name: "sub", IP: netip.MustParseAddr("192.0.2.1"), TTL: time.Hour},
+		libdns.Address{Name: "*", IP: netip.MustParseAddr("192.0.2.3"), TTL: time.Hour},
+		libdns.NS{Name: "@", Target: "ns1.loopia.se", TTL: time.Hour},
+	}
+	want := "$ORIGIN example.org.\n" +
+		"$TTL 3600\n" +
+		"@\t\tIN\tMX\t10 mx1.loopia.se.\n" +
+		"@\t\tIN\tMX\t20 mx2.loopia.se.\n" +
+		"@\t\tIN\tNS\tns1.loopia.se.\n" +
+		"@\t\tIN\tTXT\t\"v=spf1 include:\\\"loopia\\\" ~all\"\n" +
+		"*\t\tIN\tA\t192.0.2.3\n" +
+		"_sip._tcp\t\tIN\tSRV\t10 5 5060 sip.example.org.\n" +
+		"sub\t\tIN\tA\t192.0.2.1\n" +
+		"www.sub\t\tIN\tA\t192.0.2.2\n" +
+		"www\t300\tIN\tCNAME\texample.org.\n"
+
+	for i := 0; i < 3; i++ {
+		buf := &bytes.Buffer{}
+		assert.NoError(t, WriteZoneFile(buf, "example.org.", records))
+		assert.Equal(t, want, buf.String())
+		records = append(records[1:], records[0])
+	}
+}
+
+func TestQuoteTXT(t *testing.T) {
+	assert.Equal(t, `"a \\ b"`, quoteTXT(`a \ b`))
+	long := strings.Repeat("x", 300)
+	assert.Equal(t, `"`+long[:255]+`" "`+long[255:]+`"`, quoteTXT(long))
+	assert.Equal(t, `""`, quoteTXT(""))
+
+	// a two byte character that would straddle the limit starts the next string
+	split := strings.Repeat("x", 254) + "é"
+	assert.Equal(t, `"`+split[:254]+`" "é"`, quoteTXT(split))
+	assert.Equal(t, `"räksmörgås ☃"`, quoteTXT("räksmörgås ☃"))
+	assert.Equal(t, `"a\009b\000\127 \"q\""`, quoteTXT("a\tb\x00\x7f \"q\""))
+	assert.Equal(t, `"bad \255 utf-8"`, quoteTXT("bad \xff utf-8"))
+
+	for _, text := range []string{split, "räksmörgås ☃", "a\tb\x00\x7f \"q\" \\", "bad \xff utf-8", strings.Repeat("\x01é", 200)} {
+		buf := &bytes.Buffer{}
+		assert.NoError(t, WriteZoneFile(buf, "example.org", []libdns.Record{libdns.TXT{Name: "@", Text: text, TTL: time.Hour}}))
+		for _, q := range strings.Split(strings.TrimSpace(buf.String()[strings.Index(buf.String(), `"`):]), `" "`) {
+			raw, err := unescapeZone(strings.Trim(q, `"`))
+			assert.NoError(t, err)
+			assert.LessOrEqual(t, len(raw), txtChunk)
+			assert.True(t, utf8.ValidString(raw) || !utf8.ValidString(text), "strings should only be split between characters")
+		}
+		records, err := ParseZoneFile(buf, "example.org", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []libdns.Record{libdns.TXT{Name: "@", Text: text, TTL: time.Hour}}, records, "%q", text)
+	}
+}
+
+func TestProvider_ExportZone(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	zone.add("@", loopiaRecord{Type: "MX", RData: "mx1.loopia.se.", Priority: 10, TTL: 3600})
+	zone.add("www", loopiaRecord{Type: "A", RData: "192.0.2.1", TTL: 3600})
+	p := tc.getProvider()
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, p.ExportZone(context.TODO(), "example.org", buf))
+	assert.Equal(t, "$ORIGIN example.org.\n$TTL 3600\n"+
+		"@\t\tIN\tMX\t10 mx1.loopia.se.\n"+
+		"www\t\tIN\tA\t192.0.2.1\n", buf.String())
+}
+
+func TestProvider_ExportSubZone(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	zone.add("@", loopiaRecord{Type: "MX", RData: "mx1.loopia.se.", Priority: 10, TTL: 3600})
+	zone.add("www", loopiaRecord{Type: "A", RData: "192.0.2.1", TTL: 3600})
+	zone.add("sub", loopiaRecord{Type: "A", RData: "192.0.2.2", TTL: 3600})
+	zone.add("www.sub", loopiaRecord{Type: "A", RData: "192.0.2.3", TTL: 300})
+	p := tc.getProvider()
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, p.ExportZone(context.TODO(), "sub.example.org.", buf))
+	assert.Equal(t, "$ORIGIN sub.example.org.\n$TTL 300\n"+
+		"@\t3600\tIN\tA\t192.0.2.2\n"+
+		"www\t\tIN\tA\t192.0.2.3\n", buf.String())
+}
@@ -0,0 +1,95 @@
+package loopia
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// ImportMode selects how ImportZone applies records.
+type ImportMode int
+
+const (
+	// ImportAppend adds the records that do not exist yet, like AppendRecords.
+	ImportAppend ImportMode = iota
+	// ImportSet makes the imported records the only ones of their name and
+	// type, like SetRecords.
+	ImportSet
+)
+
+// importTypes are the record types Loopia DNS can hold.
+var importTypes = map[string]bool{
+	"A":     true,
+	"AAAA":  true,
+	"CAA":   true,
+	"CNAME": true,
+	"LOC":   true,
+	"MX":    true,
+	"NAPTR": true,
+	"NS":    true,
+	"SRV":   true,
+	"SSHFP": true,
+	"TLSA":  true,
+	"TXT":   true,
+}
+
+// ImportResult is the result of ImportZone.
+type ImportResult struct {
+	// Records are the supported records of the import as they are in the
+	// zone, with their Loopia IDs. With ImportAppend those are the records
+	// created. With ImportSet they are the records created, updated or kept
+	// as they were, but not the records removed nor other records of the
+	// zone. On errors only the records applied before the error are included.
+	Records []libdns.Record
+	// Unsupported are the records skipped as Loopia can not hold their type,
+	// like SOA.
+	Unsupported []libdns.Record
+}
+
+// ImportZoneFile reads the zone file at path, see ReadZoneFile, and imports
+// its records into zone.
+func (p *Provider) ImportZoneFile(ctx context.Context, zone, path string, mode ImportMode) (*ImportResult, error) {
+	records, err := ReadZoneFile(path, zone)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error reading zone file: %w", err)
+	}
+	return p.ImportZone(ctx, zone, records, mode)
+}
+
+// ImportZone applies records, for example from ParseZoneFile, to the zone.
+// Records of types Loopia can not hold are not applied but returned in the
+// result. The result is returned with the records applied so far on errors.
+func (p *Provider) ImportZone(ctx context.Context, zone string, records []libdns.Record, mode ImportMode) (*ImportResult, error) {
+	result := &ImportResult{Records: []libdns.Record{}, Unsupported: []libdns.Record{}}
+	supported := []libdns.Record{}
+	for _, r := range records {
+		if importTypes[strings.ToUpper(r.RR().Type)] {
+			supported = append(supported, r)
+		} else {
+			result.Unsupported = append(result.Unsupported, r)
+		}
+	}
+	if len(result.Unsupported) > 0 {
+		p.log().WarnContext(ctx, "skipping unsupported records", LogKeyZone, zone, "count", len(result.Unsupported))
+	}
+	if len(supported) == 0 {
+		return result, nil
+	}
+
+	var applied []libdns.Record
+	var err error
+	switch mode {
+	case ImportAppend:
+		applied, err = p.AppendRecords(ctx, zone, supported)
+	case ImportSet:
+		applied, err = p.SetRecords(ctx, zone, supported)
+	default:
+		return result, fmt.Errorf("invalid import mode %d", mode)
+	}
+	if applied != nil {
+		result.Records = applied
+	}
+	return result, err
+}
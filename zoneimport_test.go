@@ -0,0 +1,37 @@
+package loopia
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider_ImportZoneFile(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	old := zone.add("www", loopiaRecord{Type: "A", RData: "198.51.100.1", TTL: 300})
+	zone.add("@", loopiaRecord{Type: "A", RData: "198.51.100.2", TTL: 300})
+	p := tc.getProvider()
+
+	result, err := p.ImportZoneFile(context.TODO(), "example.org", "testdata/zones/example.org.zone", ImportSet)
+	assert.NoError(t, err)
+	assert.Len(t, result.Unsupported, 1)
+	assert.Equal(t, "SOA", result.Unsupported[0].RR().Type)
+	assert.Len(t, result.Records, 14)
+
+	www := zone.get("www")
+	assert.Len(t, www, 2)
+	assert.Equal(t, loopiaRecord{ID: old[0], Type: "A", RData: "192.0.2.1", TTL: 300}, www[0])
+	assert.Equal(t, "AAAA", www[1].Type)
+	assert.Equal(t, "198.51.100.2", zone.get("@")[0].RData, "records of types not imported are kept")
+	assert.Equal(t, loopiaRecord{ID: zone.get("@")[3].ID, Type: "MX", RData: "mx1.example.org.", Priority: 10, TTL: 3600}, zone.get("@")[3])
+	assert.Len(t, zone.get("a.b.sub"), 1)
+
+	result, err = p.ImportZoneFile(context.TODO(), "example.org", "testdata/zones/example.org.zone", ImportAppend)
+	assert.NoError(t, err)
+	assert.Len(t, result.Records, 14)
+	assert.Len(t, zone.get("www"), 2, "appending the same records again changes nothing")
+}
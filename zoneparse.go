@@ -0,0 +1,456 @@
+package loopia
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// defaultZoneTTL is the TTL of records in a zone file without $TTL or an
+// earlier TTL to default to.
+const defaultZoneTTL = time.Hour
+
+// ReadZoneFile parses the zone file at path, see ParseZoneFile. $INCLUDE is
+// resolved relative to the directory of the file.
+func ReadZoneFile(path, zone string) ([]libdns.Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseZoneFile(f, zone, os.DirFS(filepath.Dir(path)))
+}
+
+// ParseZoneFile parses an RFC 1035 master file with records for zone, which
+// is also the origin until the file sets $ORIGIN. The records returned have
+// names relative to zone, names outside of it are an error. Target names are
+// made fully qualified. Records of types libdns does not know are returned as
+// libdns.RR.
+//
+// $INCLUDE reads files from fsys, a nil fsys makes $INCLUDE an error.
+func ParseZoneFile(r io.Reader, zone string, fsys fs.FS) ([]libdns.Record, error) {
+	zp := &zoneParser{
+		zone: strings.ToLower(cleanZone(zone)) + ".",
+		fsys: fsys,
+		ttl:  -1,
+	}
+	if err := zp.parse(r, "", zp.zone, 0); err != nil {
+		return nil, err
+	}
+	return zp.records, nil
+}
+
+// zoneToken is a word or quoted string in a zone file. The text of quoted
+// strings is without the quotes, escapes are kept in either.
+type zoneToken struct {
+	text   string
+	quoted bool
+}
+
+// zoneLine is a logical line, parentheses joined, of a zone file.
+type zoneLine struct {
+	line   int
+	blank  bool
+	tokens []zoneToken
+}
+
+type zoneParser struct {
+	zone string
+	fsys fs.FS
+	ttl  time.Duration
+	// dollarTTL is set once $TTL is seen
+	dollarTTL bool
+	owner     string
+	records   []libdns.Record
+}
+
+// maxIncludeDepth stops $INCLUDE loops.
+const maxIncludeDepth = 10
+
+func (zp *zoneParser) parse(r io.Reader, file, origin string, depth int) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	lines, err := splitZoneLines(string(b))
+	if err != nil {
+		return zoneError(file, err)
+	}
+	for _, l := range lines {
+		if err := zp.parseLine(l, &origin, depth); err != nil {
+			return zoneError(file, fmt.Errorf("line %d: %w", l.line, err))
+		}
+	}
+	return nil
+}
+
+func zoneError(file string, err error) error {
+	if file == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", file, err)
+}
+
+func (zp *zoneParser) parseLine(l zoneLine, origin *string, depth int) error {
+	tokens := l.tokens
+	if !l.blank && !tokens[0].quoted && strings.HasPrefix(tokens[0].text, "$") {
+		return zp.directive(tokens, origin, depth)
+	}
+
+	owner := zp.owner
+	if !l.blank {
+		owner = strings.ToLower(absoluteName(tokens[0].text, *origin))
+		tokens = tokens[1:]
+	}
+	if owner == "" {
+		return fmt.Errorf("no owner name")
+	}
+	zp.owner = owner
+
+	ttl, explicit, rrType := time.Duration(-1), false, ""
+	for len(tokens) > 0 && rrType == "" {
+		t := tokens[0].text
+		tokens = tokens[1:]
+		switch {
+		case isZoneClass(t):
+		case ttl < 0 && isZoneTTL(t):
+			d, err := parseZoneTTL(t)
+			if err != nil {
+				return err
+			}
+			ttl, explicit = d, true
+		default:
+			rrType = strings.ToUpper(t)
+		}
+	}
+	if rrType == "" {
+		return fmt.Errorf("no record type")
+	}
+	if explicit && !zp.dollarTTL {
+		// without $TTL the last explicit TTL is the default, RFC 1035 5.1
+		zp.ttl = ttl
+	}
+	if ttl < 0 {
+		ttl = zp.ttl
+	}
+	if ttl < 0 {
+		ttl = defaultZoneTTL
+	}
+
+	if owner != zp.zone && !strings.HasSuffix(owner, "."+zp.zone) {
+		return fmt.Errorf("name '%s' is outside of zone '%s'", owner, zp.zone)
+	}
+	data, err := zoneRecordData(rrType, tokens, *origin)
+	if err != nil {
+		return fmt.Errorf("%s record: %w", rrType, err)
+	}
+	record, err := libdns.RR{
+		Name: libdns.RelativeName(owner, zp.zone),
+		Type: rrType,
+		Data: data,
+		TTL:  ttl,
+	}.Parse()
+	if err != nil {
+		return err
+	}
+	zp.records = append(zp.records, record)
+	return nil
+}
+
+func (zp *zoneParser) directive(tokens []zoneToken, origin *string, depth int) error {
+	name := strings.ToUpper(tokens[0].text)
+	args := tokens[1:]
+	switch name {
+	case "$ORIGIN":
+		if len(args) != 1 || !strings.HasSuffix(args[0].text, ".") {
+			return fmt.Errorf("$ORIGIN needs a fully qualified name")
+		}
+		*origin = args[0].text
+	case "$TTL":
+		if len(args) != 1 {
+			return fmt.Errorf("$TTL needs a single TTL")
+		}
+		ttl, err := parseZoneTTL(args[0].text)
+		if err != nil {
+			return err
+		}
+		zp.ttl, zp.dollarTTL = ttl, true
+	case "$INCLUDE":
+		if len(args) < 1 || len(args) > 2 {
+			return fmt.Errorf("$INCLUDE needs a file name and an optional origin")
+		}
+		if zp.fsys == nil {
+			return fmt.Errorf("$INCLUDE is not allowed here")
+		}
+		if depth >= maxIncludeDepth {
+			return fmt.Errorf("$INCLUDE nested too deep")
+		}
+		includeOrigin := *origin
+		if len(args) == 2 {
+			includeOrigin = absoluteName(args[1].text, *origin)
+		}
+		f, err := zp.fsys.Open(args[0].text)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		// the owner and origin are restored after the included file
+		owner := zp.owner
+		err = zp.parse(f, args[0].text, includeOrigin, depth+1)
+		zp.owner = owner
+		return err
+	default:
+		return fmt.Errorf("unknown directive %s", tokens[0].text)
+	}
+	return nil
+}
+
+// zoneRecordData returns the rdata of a record as libdns expects it.
+func zoneRecordData(rrType string, tokens []zoneToken, origin string) (string, error) {
+	fields := func(n int) error {
+		if len(tokens) != n {
+			return fmt.Errorf("expected %d fields, got %d", n, len(tokens))
+		}
+		return nil
+	}
+	switch rrType {
+	case "TXT", "SPF":
+		text := strings.Builder{}
+		for _, t := range tokens {
+			s, err := unescapeZone(t.text)
+			if err != nil {
+				return "", err
+			}
+			text.WriteString(s)
+		}
+		return text.String(), nil
+	case "CNAME", "NS", "PTR", "DNAME":
+		if err := fields(1); err != nil {
+			return "", err
+		}
+		return absoluteName(tokens[0].text, origin), nil
+	case "MX":
+		if err := fields(2); err != nil {
+			return "", err
+		}
+		return tokens[0].text + " " + absoluteName(tokens[1].text, origin), nil
+	case "SRV":
+		if err := fields(4); err != nil {
+			return "", err
+		}
+		return tokens[0].text + " " + tokens[1].text + " " + tokens[2].text + " " + absoluteName(tokens[3].text, origin), nil
+	case "CAA":
+		if err := fields(3); err != nil {
+			return "", err
+		}
+		value, err := unescapeZone(tokens[2].text)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s %q", tokens[0].text, tokens[1].text, value), nil
+	}
+	parts := []string{}
+	for _, t := range tokens {
+		if t.quoted {
+			parts = append(parts, `"`+t.text+`"`)
+		} else {
+			parts = append(parts, t.text)
+		}
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// absoluteName makes name fully qualified, relative to origin.
+func absoluteName(name, origin string) string {
+	if name == "@" {
+		return origin
+	}
+	if strings.HasSuffix(name, ".") && !strings.HasSuffix(name, `\.`) {
+		return name
+	}
+	return name + "." + origin
+}
+
+func isZoneClass(s string) bool {
+	switch strings.ToUpper(s) {
+	case "IN", "CH", "CS", "HS":
+		return true
+	}
+	return false
+}
+
+func isZoneTTL(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+// parseZoneTTL parses a TTL in seconds, or with BIND units like 1h30m.
+func parseZoneTTL(s string) (time.Duration, error) {
+	if n, err := strconv.ParseUint(s, 10, 31); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	units := map[byte]time.Duration{
+		's': time.Second,
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+	var ttl time.Duration
+	n := ""
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= '0' && c <= '9' {
+			n += string(c)
+			continue
+		}
+		unit, ok := units[c|0x20]
+		if !ok || n == "" {
+			return 0, fmt.Errorf("invalid TTL '%s'", s)
+		}
+		v, _ := strconv.Atoi(n)
+		ttl += time.Duration(v) * unit
+		n = ""
+	}
+	if n != "" {
+		return 0, fmt.Errorf("invalid TTL '%s'", s)
+	}
+	return ttl, nil
+}
+
+// unescapeZone resolves \X and \DDD escapes.
+func unescapeZone(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 >= len(s) {
+			return "", fmt.Errorf("dangling escape in '%s'", s)
+		}
+		if i+3 < len(s) && isDigits(s[i+1:i+4]) {
+			v, _ := strconv.Atoi(s[i+1 : i+4])
+			if v > 255 {
+				return "", fmt.Errorf("invalid escape in '%s'", s)
+			}
+			b.WriteByte(byte(v))
+			i += 3
+			continue
+		}
+		b.WriteByte(s[i+1])
+		i++
+	}
+	return b.String(), nil
+}
+
+func isDigits(s string) bool {
+	return s != "" && digits(s)
+}
+
+// splitZoneLines splits a zone file into logical lines, removing comments
+// and joining lines in parentheses.
+func splitZoneLines(content string) ([]zoneLine, error) {
+	lines := []zoneLine{}
+	current := zoneLine{line: 1}
+	line, depth := 1, 0
+	token, inToken := strings.Builder{}, false
+	startOfLine := true
+
+	endToken := func() {
+		if inToken {
+			current.tokens = append(current.tokens, zoneToken{text: token.String()})
+			token.Reset()
+			inToken = false
+		}
+	}
+	endLine := func() {
+		endToken()
+		if len(current.tokens) > 0 {
+			lines = append(lines, current)
+		}
+		current = zoneLine{line: line}
+	}
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		if startOfLine {
+			startOfLine = false
+			current.blank = c == ' ' || c == '\t'
+		}
+		switch c {
+		case '\n':
+			line++
+			if depth == 0 {
+				endLine()
+				startOfLine = true
+			} else {
+				endToken()
+			}
+		case ' ', '\t', '\r':
+			endToken()
+		case ';':
+			endToken()
+			for i+1 < len(content) && content[i+1] != '\n' {
+				i++
+			}
+		case '(':
+			endToken()
+			depth++
+		case ')':
+			endToken()
+			if depth == 0 {
+				return nil, fmt.Errorf("line %d: unbalanced parentheses", line)
+			}
+			depth--
+		case '"':
+			endToken()
+			start := line
+			s := strings.Builder{}
+			for {
+				i++
+				if i >= len(content) {
+					return nil, fmt.Errorf("line %d: unterminated quoted string", start)
+				}
+				if content[i] == '\n' {
+					line++
+				}
+				if content[i] == '\\' && i+1 < len(content) {
+					s.WriteByte(content[i])
+					i++
+					s.WriteByte(content[i])
+					continue
+				}
+				if content[i] == '"' {
+					break
+				}
+				s.WriteByte(content[i])
+			}
+			current.tokens = append(current.tokens, zoneToken{text: s.String(), quoted: true})
+		case '\\':
+			inToken = true
+			token.WriteByte(c)
+			if i+1 < len(content) {
+				i++
+				token.WriteByte(content[i])
+			}
+		default:
+			inToken = true
+			token.WriteByte(c)
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("line %d: unbalanced parentheses", line)
+	}
+	endLine()
+	return lines, nil
+}
@@ -0,0 +1,91 @@
+package loopia
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadZoneFile(t *testing.T) {
+	records, err := ReadZoneFile("testdata/zones/example.org.zone", "example.org")
+	assert.NoError(t, err)
+	assert.Equal(t, []libdns.Record{
+		libdns.RR{Name: "@", Type: "SOA", TTL: time.Hour, Data: "ns1.example.net. hostmaster.example.org. 2026101901 3h 1h 1w 5m"},
+		libdns.NS{Name: "@", Target: "ns1.loopia.se.", TTL: time.Hour},
+		libdns.NS{Name: "@", Target: "ns2.loopia.se.", TTL: time.Hour},
+		libdns.MX{Name: "@", Preference: 10, Target: "mx1.example.org.", TTL: time.Hour},
+		libdns.MX{Name: "@", Preference: 20, Target: "mx2.loopia.se.", TTL: time.Hour},
+		libdns.TXT{Name: "@", Text: "v=spf1 include:_spf.loopia.se ~all", TTL: time.Hour},
+		libdns.Address{Name: "www", IP: netip.MustParseAddr("192.0.2.1"), TTL: 5 * time.Minute},
+		libdns.Address{Name: "www", IP: netip.MustParseAddr("2001:db8::1"), TTL: 5 * time.Minute},
+		libdns.TXT{Name: "long", Text: `first part, second "part"`, TTL: time.Hour},
+		libdns.TXT{Name: "esc", Text: `semi;colon and\backslash`, TTL: time.Hour},
+		libdns.SRV{Service: "sip", Transport: "tcp", Name: "@", Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.org.", TTL: time.Hour},
+		libdns.CAA{Name: "mail", Flags: 0, Tag: "issue", Value: "letsencrypt.org", TTL: time.Hour},
+		libdns.CNAME{Name: "www.sub", Target: "sub.example.org.", TTL: time.Hour},
+		libdns.Address{Name: "a.b.sub", IP: netip.MustParseAddr("192.0.2.2"), TTL: time.Hour},
+		libdns.Address{Name: "extra.sub", IP: netip.MustParseAddr("192.0.2.3"), TTL: time.Hour},
+	}, records)
+}
+
+func TestParseZoneFile_roundTrip(t *testing.T) {
+	records, err := ReadZoneFile("testdata/zones/example.org.zone", "example.org.")
+	assert.NoError(t, err)
+	buf := &bytes.Buffer{}
+	assert.NoError(t, WriteZoneFile(buf, "example.org", records))
+	again, err := ParseZoneFile(buf, "example.org", nil)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, records, again)
+}
+
+func TestParseZoneFile_ttl(t *testing.T) {
+	records, err := ParseZoneFile(strings.NewReader("a A 192.0.2.1\nb 300 A 192.0.2.2\nc A 192.0.2.3\n"), "example.org", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultZoneTTL, records[0].RR().TTL)
+	assert.Equal(t, 300*time.Second, records[1].RR().TTL)
+	assert.Equal(t, 300*time.Second, records[2].RR().TTL, "the last TTL is the default without $TTL")
+}
+
+func TestParseZoneFile_include(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sub.zone":  {Data: []byte("www A 192.0.2.1\n")},
+		"loop.zone": {Data: []byte("$INCLUDE loop.zone\n")},
+	}
+	records, err := ParseZoneFile(strings.NewReader("$INCLUDE sub.zone sub\nwww A 192.0.2.2\n"), "example.org", fsys)
+	assert.NoError(t, err)
+	assert.Equal(t, "www.sub", records[0].RR().Name)
+	assert.Equal(t, "www", records[1].RR().Name)
+
+	_, err = ParseZoneFile(strings.NewReader("$INCLUDE loop.zone\n"), "example.org", fsys)
+	assert.ErrorContains(t, err, "nested too deep")
+	_, err = ParseZoneFile(strings.NewReader("$INCLUDE sub.zone\n"), "example.org", nil)
+	assert.ErrorContains(t, err, "not allowed")
+}
+
+func TestParseZoneFile_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		err  string
+	}{
+		{"outside", "www.example.com. A 192.0.2.1\n", "line 1: name 'www.example.com.' is outside of zone 'example.org.'"},
+		{"parens", "www A (\n192.0.2.1\n", "unbalanced parentheses"},
+		{"quote", "www TXT \"open\n", "line 1: unterminated quoted string"},
+		{"no type", "\n\nwww 300 IN\n", "line 3: no record type"},
+		{"bad data", "www MX mail\n", "line 1: MX record: expected 2 fields, got 1"},
+		{"no owner", " A 192.0.2.1\n", "line 1: no owner name"},
+		{"directive", "$GENERATE 1-2 a$ A 192.0.2.$\n", "line 1: unknown directive $GENERATE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseZoneFile(strings.NewReader(tt.in), "example.org", nil)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,644 @@
+package loopia
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+	"gopkg.in/yaml.v3"
+)
+
+// ZoneSpec is a zone described as YAML or JSON, for reviewing DNS changes as
+// text. Records are grouped by name and have typed fields:
+//
+//	zone: example.org
+//	ttl: 3600
+//	records:
+//	  "@":
+//	    - type: A
+//	      value: 192.0.2.1
+//	    - type: MX
+//	      preference: 10
+//	      target: mx1.example.org.
+//	    - type: TXT
+//	      text: v=spf1 mx -all
+//	  _sip._tcp:
+//	    - type: SRV
+//	      priority: 10
+//	      weight: 5
+//	      port: 5060
+//	      target: sip.example.org.
+//	      ttl: 300
+//
+// The fields of each type are in specFields, types without typed fields use
+// data, the rdata as in a zone file.
+type ZoneSpec struct {
+	Zone string
+	// TTL in seconds of records without their own, defaults to an hour.
+	TTL   int
+	Names []SpecName
+}
+
+// SpecName is the records of a name in a ZoneSpec.
+type SpecName struct {
+	// Name is relative to the zone, "@" for the apex.
+	Name    string
+	Records []SpecRecord
+}
+
+// SpecRecord is a record in a ZoneSpec. Only the fields of its type are used.
+type SpecRecord struct {
+	Type string
+	// TTL in seconds, 0 for the TTL of the spec.
+	TTL        int
+	Value      string
+	Text       string
+	Target     string
+	Preference uint16
+	Priority   uint16
+	Weight     uint16
+	Port       uint16
+	Flags      uint8
+	Tag        string
+	Data       string
+}
+
+// specFields are the fields of the record types Loopia can hold, other than
+// type and ttl.
+var specFields = map[string][]string{
+	"A":     {"value"},
+	"AAAA":  {"value"},
+	"CAA":   {"flags", "tag", "value"},
+	"CNAME": {"target"},
+	"LOC":   {"data"},
+	"MX":    {"preference", "target"},
+	"NAPTR": {"data"},
+	"NS":    {"target"},
+	"SRV":   {"priority", "weight", "port", "target"},
+	"SSHFP": {"data"},
+	"TLSA":  {"data"},
+	"TXT":   {"text"},
+}
+
+// SpecError is a problem found in a zone spec, at a line and column of it.
+type SpecError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *SpecError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// ReadZoneSpec parses the zone spec at path, see ParseZoneSpec.
+func ReadZoneSpec(path string) (*ZoneSpec, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseZoneSpec(f)
+}
+
+// ParseZoneSpec parses and validates a zone spec in YAML or JSON. Unknown
+// keys, fields not used by the type of a record, missing fields and invalid
+// values are errors. All problems found are returned, joined, as *SpecError.
+func ParseZoneSpec(r io.Reader) (*ZoneSpec, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, &SpecError{Line: 1, Column: 1, Message: "empty zone spec"}
+	}
+	sp := &specParser{}
+	spec := sp.parse(doc.Content[0])
+	if len(sp.errs) > 0 {
+		return nil, errors.Join(sp.errs...)
+	}
+	return spec, nil
+}
+
+// ZoneSpecFromRecords returns the spec of records with names relative to
+// zone. The most common TTL becomes the TTL of the spec and names and records
+// are sorted like in WriteZoneFile.
+func ZoneSpecFromRecords(zone string, records []libdns.Record) (*ZoneSpec, error) {
+	rrs := make([]libdns.RR, 0, len(records))
+	for _, r := range records {
+		rr := r.RR()
+		rr.Type = strings.ToUpper(rr.Type)
+		rr.Name = planName(rr.Name)
+		rrs = append(rrs, rr)
+	}
+	sort.SliceStable(rrs, func(i, j int) bool {
+		a, b := rrs[i], rrs[j]
+		if a.Name != b.Name {
+			return nameLess(a.Name, b.Name)
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Data < b.Data
+	})
+	ttl := defaultTTL(rrs)
+	spec := &ZoneSpec{Zone: cleanZone(zone), TTL: int(ttl / time.Second)}
+	for _, rr := range rrs {
+		sr, err := specRecord(rr)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s record: %w", rr.Name, rr.Type, err)
+		}
+		if rr.TTL != ttl {
+			sr.TTL = int(rr.TTL / time.Second)
+		}
+		if n := len(spec.Names); n > 0 && spec.Names[n-1].Name == rr.Name {
+			spec.Names[n-1].Records = append(spec.Names[n-1].Records, sr)
+		} else {
+			spec.Names = append(spec.Names, SpecName{Name: rr.Name, Records: []SpecRecord{sr}})
+		}
+	}
+	return spec, nil
+}
+
+func specRecord(rr libdns.RR) (SpecRecord, error) {
+	sr := SpecRecord{Type: rr.Type}
+	if _, ok := specFields[rr.Type]; !ok {
+		return sr, fmt.Errorf("type not supported")
+	}
+	parsed, err := rr.Parse()
+	if err != nil {
+		return sr, err
+	}
+	switch r := parsed.(type) {
+	case libdns.Address:
+		sr.Value = r.IP.String()
+	case libdns.CAA:
+		sr.Flags, sr.Tag, sr.Value = r.Flags, r.Tag, r.Value
+	case libdns.CNAME:
+		sr.Target = r.Target
+	case libdns.MX:
+		sr.Preference, sr.Target = r.Preference, r.Target
+	case libdns.NS:
+		sr.Target = r.Target
+	case libdns.SRV:
+		sr.Priority, sr.Weight, sr.Port, sr.Target = r.Priority, r.Weight, r.Port, r.Target
+	case libdns.TXT:
+		sr.Text = r.Text
+	default:
+		sr.Data = rr.Data
+	}
+	return sr, nil
+}
+
+// Records returns the records of the spec, with names relative to its zone.
+func (s *ZoneSpec) Records() ([]libdns.Record, error) {
+	ttl := time.Duration(s.TTL) * time.Second
+	if ttl == 0 {
+		ttl = defaultZoneTTL
+	}
+	records := []libdns.Record{}
+	for _, n := range s.Names {
+		for _, sr := range n.Records {
+			rr := libdns.RR{Name: n.Name, Type: sr.Type, Data: sr.data(), TTL: ttl}
+			if sr.TTL > 0 {
+				rr.TTL = time.Duration(sr.TTL) * time.Second
+			}
+			r, err := rr.Parse()
+			if err != nil {
+				return nil, fmt.Errorf("%s %s record: %w", n.Name, sr.Type, err)
+			}
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+// data returns the rdata of the record as libdns has it.
+func (r SpecRecord) data() string {
+	switch r.Type {
+	case "A", "AAAA":
+		return r.Value
+	case "CAA":
+		return fmt.Sprintf("%d %s %q", r.Flags, r.Tag, r.Value)
+	case "CNAME", "NS":
+		return r.Target
+	case "MX":
+		return fmt.Sprintf("%d %s", r.Preference, r.Target)
+	case "SRV":
+		return fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, r.Target)
+	case "TXT":
+		return r.Text
+	}
+	return r.Data
+}
+
+// field returns the value of a field named as in specFields.
+func (r SpecRecord) field(name string) interface{} {
+	switch name {
+	case "value":
+		return r.Value
+	case "text":
+		return r.Text
+	case "target":
+		return r.Target
+	case "preference":
+		return r.Preference
+	case "priority":
+		return r.Priority
+	case "weight":
+		return r.Weight
+	case "port":
+		return r.Port
+	case "flags":
+		return r.Flags
+	case "tag":
+		return r.Tag
+	}
+	return r.Data
+}
+
+// PlanZoneSpec compares the zone of spec with its records, see Plan.
+func (p *Provider) PlanZoneSpec(ctx context.Context, spec *ZoneSpec, opts PlanOptions) (*Plan, error) {
+	records, err := spec.Records()
+	if err != nil {
+		return nil, err
+	}
+	return p.Plan(ctx, spec.Zone, records, opts)
+}
+
+// WriteYAML writes the spec to w as YAML.
+func (s *ZoneSpec) WriteYAML(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// WriteJSON writes the spec to w as indented JSON.
+func (s *ZoneSpec) WriteJSON(w io.Writer) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	if err := json.Indent(buf, b, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// MarshalYAML keeps the names and the fields of records in order.
+func (s *ZoneSpec) MarshalYAML() (interface{}, error) {
+	names := &yaml.Node{Kind: yaml.MappingNode}
+	for _, n := range s.Names {
+		records := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, r := range n.Records {
+			node := &yaml.Node{Kind: yaml.MappingNode}
+			for _, kv := range r.fields() {
+				node.Content = append(node.Content, yamlScalar(kv.key), yamlScalar(kv.value))
+			}
+			records.Content = append(records.Content, node)
+		}
+		names.Content = append(names.Content, yamlScalar(n.Name), records)
+	}
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	root.Content = append(root.Content, yamlScalar("zone"), yamlScalar(s.Zone))
+	if s.TTL > 0 {
+		root.Content = append(root.Content, yamlScalar("ttl"), yamlScalar(s.TTL))
+	}
+	root.Content = append(root.Content, yamlScalar("records"), names)
+	return root, nil
+}
+
+func yamlScalar(v interface{}) *yaml.Node {
+	n := &yaml.Node{}
+	_ = n.Encode(v)
+	return n
+}
+
+// MarshalJSON keeps the names and the fields of records in order.
+func (s *ZoneSpec) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, `{"zone":%s`, mustJSON(s.Zone))
+	if s.TTL > 0 {
+		fmt.Fprintf(buf, `,"ttl":%d`, s.TTL)
+	}
+	buf.WriteString(`,"records":{`)
+	for i, n := range s.Names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(buf, `%s:[`, mustJSON(n.Name))
+		for j, r := range n.Records {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('{')
+			for k, kv := range r.fields() {
+				if k > 0 {
+					buf.WriteByte(',')
+				}
+				fmt.Fprintf(buf, `%s:%s`, mustJSON(kv.key), mustJSON(kv.value))
+			}
+			buf.WriteByte('}')
+		}
+		buf.WriteByte(']')
+	}
+	buf.WriteString("}}")
+	return buf.Bytes(), nil
+}
+
+func mustJSON(v interface{}) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+type specField struct {
+	key   string
+	value interface{}
+}
+
+// fields returns the fields of the record as written in a spec.
+func (r SpecRecord) fields() []specField {
+	fields := []specField{{"type", r.Type}}
+	for _, name := range specFields[r.Type] {
+		fields = append(fields, specField{name, r.field(name)})
+	}
+	if r.TTL > 0 {
+		fields = append(fields, specField{"ttl", r.TTL})
+	}
+	return fields
+}
+
+// specParser reads a ZoneSpec from a YAML node tree, collecting errors.
+type specParser struct {
+	errs []error
+}
+
+func (sp *specParser) errorf(n *yaml.Node, format string, args ...interface{}) {
+	sp.errs = append(sp.errs, &SpecError{Line: n.Line, Column: n.Column, Message: fmt.Sprintf(format, args...)})
+}
+
+// mapping returns the keys and values of a mapping node, reporting keys that
+// are not allowed or repeated.
+func (sp *specParser) mapping(n *yaml.Node, what string, allowed func(key string) bool) (map[string]*yaml.Node, bool) {
+	if n.Kind != yaml.MappingNode {
+		sp.errorf(n, "%s must be a mapping", what)
+		return nil, false
+	}
+	values := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if k.Kind != yaml.ScalarNode {
+			sp.errorf(k, "%s keys must be strings", what)
+			continue
+		}
+		if _, ok := values[k.Value]; ok {
+			sp.errorf(k, "duplicate key '%s' in %s", k.Value, what)
+			continue
+		}
+		if allowed != nil && !allowed(k.Value) {
+			sp.errorf(k, "unknown key '%s' in %s", k.Value, what)
+			continue
+		}
+		values[k.Value] = v
+	}
+	return values, true
+}
+
+func (sp *specParser) str(n *yaml.Node, key string) (string, bool) {
+	if n.Kind != yaml.ScalarNode || n.Tag == "!!null" {
+		sp.errorf(n, "%s must be a string", key)
+		return "", false
+	}
+	return n.Value, true
+}
+
+func (sp *specParser) uint(n *yaml.Node, key string, bits int) (uint64, bool) {
+	if n.Kind != yaml.ScalarNode || n.Tag != "!!int" {
+		sp.errorf(n, "%s must be an integer", key)
+		return 0, false
+	}
+	v, err := strconv.ParseUint(n.Value, 10, bits)
+	if err != nil {
+		sp.errorf(n, "%s must be an integer from 0 to %d", key, uint64(1)<<bits-1)
+		return 0, false
+	}
+	return v, true
+}
+
+func (sp *specParser) ttl(n *yaml.Node) int {
+	v, ok := sp.uint(n, "ttl", 31)
+	if ok && v == 0 {
+		sp.errorf(n, "ttl must be positive")
+	}
+	return int(v)
+}
+
+func (sp *specParser) parse(root *yaml.Node) *ZoneSpec {
+	spec := &ZoneSpec{}
+	keys, ok := sp.mapping(root, "zone spec", func(key string) bool {
+		return key == "zone" || key == "ttl" || key == "records"
+	})
+	if !ok {
+		return spec
+	}
+	if n, ok := keys["zone"]; !ok {
+		sp.errorf(root, "zone is missing")
+	} else if zone, ok := sp.str(n, "zone"); ok {
+		if !validZone(zone) {
+			sp.errorf(n, "invalid zone '%s'", zone)
+		}
+		spec.Zone = strings.ToLower(cleanZone(zone))
+	}
+	if n, ok := keys["ttl"]; ok {
+		spec.TTL = sp.ttl(n)
+	}
+	n, ok := keys["records"]
+	if !ok {
+		sp.errorf(root, "records are missing")
+		return spec
+	}
+	names, ok := sp.mapping(n, "records", nil)
+	if !ok {
+		return spec
+	}
+	// walk the content for the order of the names
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k := n.Content[i]
+		if names[k.Value] != n.Content[i+1] {
+			continue
+		}
+		name := strings.ToLower(k.Value)
+		if name == "" || strings.HasSuffix(name, ".") || strings.ContainsAny(name, " \t") {
+			sp.errorf(k, "invalid name '%s', names are relative to the zone and '@' is the apex", k.Value)
+			continue
+		}
+		if seen[name] {
+			sp.errorf(k, "duplicate name '%s'", k.Value)
+			continue
+		}
+		seen[name] = true
+		spec.Names = append(spec.Names, SpecName{Name: name, Records: sp.records(name, n.Content[i+1])})
+	}
+	return spec
+}
+
+func (sp *specParser) records(name string, n *yaml.Node) []SpecRecord {
+	records := []SpecRecord{}
+	if n.Kind != yaml.SequenceNode {
+		sp.errorf(n, "records of '%s' must be a list", name)
+		return records
+	}
+	for _, rn := range n.Content {
+		if r, ok := sp.record(name, rn); ok {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// srvName matches the names SRV records must have.
+var srvName = regexp.MustCompile(`^_[^.]+\._[^.]+(\..+)?$`)
+
+func (sp *specParser) record(name string, n *yaml.Node) (SpecRecord, bool) {
+	r := SpecRecord{}
+	keys, ok := sp.mapping(n, "record", nil)
+	if !ok {
+		return r, false
+	}
+	tn, ok := keys["type"]
+	if !ok {
+		sp.errorf(n, "type is missing")
+		return r, false
+	}
+	if r.Type, ok = sp.str(tn, "type"); !ok {
+		return r, false
+	}
+	r.Type = strings.ToUpper(r.Type)
+	fields, ok := specFields[r.Type]
+	if !ok {
+		sp.errorf(tn, "type %s is not supported", r.Type)
+		return r, false
+	}
+
+	valid := true
+	allowed := map[string]bool{"type": true, "ttl": true}
+	for _, f := range fields {
+		allowed[f] = true
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if k := n.Content[i]; !allowed[k.Value] {
+			sp.errorf(k, "%s records have no field '%s'", r.Type, k.Value)
+			valid = false
+		}
+	}
+	if v, ok := keys["ttl"]; ok {
+		r.TTL = sp.ttl(v)
+	}
+	for _, f := range fields {
+		v, ok := keys[f]
+		if !ok {
+			sp.errorf(n, "%s record is missing %s", r.Type, f)
+			valid = false
+			continue
+		}
+		valid = sp.field(&r, f, v) && valid
+	}
+	if !valid {
+		return r, false
+	}
+
+	switch r.Type {
+	case "A", "AAAA":
+		addr, err := netip.ParseAddr(r.Value)
+		if err != nil || addr.Zone() != "" || (r.Type == "A") != addr.Is4() {
+			sp.errorf(keys["value"], "invalid %s address '%s'", r.Type, r.Value)
+			return r, false
+		}
+	case "CAA":
+		if r.Tag == "" || strings.ContainsAny(r.Tag, " \t") {
+			sp.errorf(keys["tag"], "invalid CAA tag '%s'", r.Tag)
+			return r, false
+		}
+		if strings.ContainsAny(r.Value, " \t") {
+			sp.errorf(keys["value"], "CAA value can not contain spaces")
+			return r, false
+		}
+	case "SRV":
+		if !srvName.MatchString(name) {
+			sp.errorf(n, "SRV records need a name like _service._proto, not '%s'", name)
+			return r, false
+		}
+	}
+	if t, ok := keys["target"]; ok && (r.Target == "" || strings.ContainsAny(r.Target, " \t")) {
+		sp.errorf(t, "invalid target '%s'", r.Target)
+		return r, false
+	}
+	if d, ok := keys["data"]; ok && strings.TrimSpace(r.Data) == "" {
+		sp.errorf(d, "data is empty")
+		return r, false
+	}
+	return r, true
+}
+
+// field sets a field named as in specFields from its node.
+func (sp *specParser) field(r *SpecRecord, name string, n *yaml.Node) bool {
+	var s string
+	var u uint64
+	ok := true
+	switch name {
+	case "preference", "priority", "weight", "port":
+		u, ok = sp.uint(n, name, 16)
+	case "flags":
+		u, ok = sp.uint(n, name, 8)
+	default:
+		s, ok = sp.str(n, name)
+	}
+	switch name {
+	case "value":
+		r.Value = s
+	case "text":
+		r.Text = s
+	case "target":
+		r.Target = s
+	case "preference":
+		r.Preference = uint16(u)
+	case "priority":
+		r.Priority = uint16(u)
+	case "weight":
+		r.Weight = uint16(u)
+	case "port":
+		r.Port = uint16(u)
+	case "flags":
+		r.Flags = uint8(u)
+	case "tag":
+		r.Tag = s
+	case "data":
+		r.Data = s
+	}
+	return ok
+}
@@ -0,0 +1,116 @@
+package loopia
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadZoneSpec(t *testing.T) {
+	spec, err := ReadZoneSpec("testdata/specs/example.org.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "example.org", spec.Zone)
+
+	records, err := spec.Records()
+	require.NoError(t, err)
+	assert.Len(t, records, 8)
+	assert.Equal(t, libdns.Address{Name: "@", IP: netip.MustParseAddr("192.0.2.1"), TTL: 5 * time.Minute}, records[0])
+	assert.Equal(t, libdns.CAA{Name: "@", Flags: 0, Tag: "issue", Value: "letsencrypt.org", TTL: time.Hour}, records[2])
+	assert.Equal(t, libdns.MX{Name: "@", Preference: 10, Target: "mx1.example.org.", TTL: time.Hour}, records[3])
+	assert.Equal(t, libdns.SRV{Service: "sip", Transport: "tcp", Name: "@", Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.org.", TTL: time.Hour}, records[5])
+	assert.Equal(t, "1 1 123456789abcdef67890123456789abcdef67890", records[6].RR().Data)
+
+	// the spec written from the records is the file read
+	again, err := ZoneSpecFromRecords("example.org.", records)
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	require.NoError(t, again.WriteYAML(buf))
+	want, err := os.ReadFile("testdata/specs/example.org.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, string(want), buf.String())
+
+	buf.Reset()
+	require.NoError(t, again.WriteJSON(buf))
+	assert.Contains(t, buf.String(), `"_sip._tcp": [`)
+	fromJSON, err := ParseZoneSpec(buf)
+	require.NoError(t, err)
+	assert.Equal(t, again, fromJSON)
+}
+
+func TestParseZoneSpec_Errors(t *testing.T) {
+	spec := strings.Join([]string{
+		"zone: example.org",
+		"ttl: -1",
+		"records:",
+		"  '@':",
+		"    - type: A",
+		"      value: 2001:db8::1",
+		"    - type: MX",
+		"      target: mx.example.org.",
+		"      weight: 5",
+		"  www.example.org.:",
+		"    - type: TXT",
+		"      text: ok",
+		"  _sip:",
+		"    - type: SRV",
+		"      priority: 1",
+		"      weight: 70000",
+		"      port: 5060",
+		"      target: sip.example.org.",
+		"  soa:",
+		"    - type: SOA",
+		"      data: x",
+		"extra: true",
+	}, "\n")
+	_, err := ParseZoneSpec(strings.NewReader(spec))
+	require.Error(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"line 22: unknown key 'extra' in zone spec",
+		"line 2: ttl must be an integer from 0 to 2147483647",
+		"line 6: invalid A address '2001:db8::1'",
+		"line 9: MX records have no field 'weight'",
+		"line 7: MX record is missing preference",
+		"line 10: invalid name 'www.example.org.', names are relative to the zone and '@' is the apex",
+		"line 16: weight must be an integer from 0 to 65535",
+		"line 20: type SOA is not supported",
+	}, "\n"), err.Error())
+
+	var specErr *SpecError
+	require.True(t, errors.As(err, &specErr))
+	assert.Equal(t, 22, specErr.Line)
+
+	_, err = ParseZoneSpec(strings.NewReader(`{"zone": "example.org", "records": {"_sip": [{"type": "SRV", "priority": 1, "weight": 1, "port": 1, "target": "x."}]}}`))
+	assert.EqualError(t, err, "line 1: SRV records need a name like _service._proto, not '_sip'")
+	_, err = ParseZoneSpec(strings.NewReader(""))
+	assert.EqualError(t, err, "line 1: empty zone spec")
+}
+
+func TestProvider_PlanZoneSpec(t *testing.T) {
+	tc := setupTest(t)
+	defer teardownTest(tc)
+	zone := newFakeZone()
+	zone.register(tc)
+	www := zone.add("www", loopiaRecord{Type: "CNAME", RData: "old.example.org.", TTL: 3600})
+	p := tc.getProvider()
+
+	spec, err := ReadZoneSpec("testdata/specs/example.org.yaml")
+	require.NoError(t, err)
+	plan, err := p.PlanZoneSpec(context.TODO(), spec, PlanOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "example.org", plan.Zone)
+	assert.Len(t, plan.Changes, 8)
+	assert.Equal(t, Change{
+		Action: ChangeUpdate, Name: "www", Type: "CNAME", ID: www[0],
+		Before: &PlanRecord{"old.example.org.", 3600},
+		After:  &PlanRecord{"example.org.", 3600},
+	}, plan.Changes[0])
+}